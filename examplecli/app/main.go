@@ -53,6 +53,11 @@ func main() {
 		panic(err)
 	}
 	fmt.Printf("Host executed random number is: %d\n", n)
+
+	// ask plugins to release their resources and disconnect
+	if err := pluginsManager.Shutdown(ctx); err != nil {
+		log.Fatal(fmt.Errorf("plugins shutdown failed: %w", err))
+	}
 }
 
 func getRandomNumber(ctx context.Context, pluginsManager *extensionmanager.WSManager) (int, error) {
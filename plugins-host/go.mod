@@ -5,5 +5,7 @@ go 1.21
 require (
 	github.com/derbylock/go-pluggable-extensions/plugins-lib v1.1.41
 	github.com/google/uuid v1.6.0
-	github.com/gorilla/websocket v1.5.3
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/gorilla/websocket v1.5.3 // indirect
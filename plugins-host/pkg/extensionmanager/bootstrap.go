@@ -0,0 +1,116 @@
+package extensionmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// BootstrapMode defines how the host passes its address and the registration secret to plugins' processes.
+type BootstrapMode int
+
+const (
+	// BootstrapEnv passes values via the PMS_SECRET and PMS_PORT (or PMS_SOCKET) environment variables.
+	BootstrapEnv BootstrapMode = iota
+	// BootstrapFD passes values via the pipe inherited by the plugin process as the file descriptor 3.
+	// The plugin reads it once during the start. It is not supported on Windows.
+	BootstrapFD
+	// BootstrapArgs passes values via the legacy -pms-port (or -pms-socket) and -pms-secret command line arguments.
+	// Any local user able to run ps could read the secret, so it should be used only for plugins
+	// built with old versions of the plugins library.
+	BootstrapArgs
+)
+
+// bootstrapFD is the number of the file descriptor inherited by the plugin in the BootstrapFD mode.
+// Descriptors 0, 1 and 2 are used by stdin, stdout and stderr.
+const bootstrapFD = 3
+
+// WithBootstrapMode sets the way the host passes its address and the secret to plugins. BootstrapEnv is used by default.
+func (m *WSManager) WithBootstrapMode(mode BootstrapMode) *WSManager {
+	m.bootstrapMode = mode
+	return m
+}
+
+func (m *WSManager) bootstrapData(secret string) pluginstypes.BootstrapData {
+	data := pluginstypes.BootstrapData{Secret: secret}
+	if addr, ok := m.lis.Addr().(*net.UnixAddr); ok {
+		data.Socket = addr.Name
+	} else {
+		data.Port = m.pmsPort
+	}
+	return data
+}
+
+// prepareBootstrap passes the bootstrap data to the plugin command according to the bootstrap mode.
+//
+// The returned function should be called after the command is started to release the host's resources.
+func (m *WSManager) prepareBootstrap(command *exec.Cmd, secret string) (func(), error) {
+	data := m.bootstrapData(secret)
+	noop := func() {}
+	switch m.bootstrapMode {
+	case BootstrapArgs:
+		if data.Socket != "" {
+			command.Args = append(command.Args, "-pms-socket", data.Socket)
+		} else {
+			command.Args = append(command.Args, "-pms-port", strconv.Itoa(data.Port))
+		}
+		command.Args = append(command.Args, "-pms-secret", data.Secret)
+		return noop, nil
+	case BootstrapFD:
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			return noop, fmt.Errorf("marshal bootstrap data: %w", err)
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return noop, fmt.Errorf("create bootstrap pipe: %w", err)
+		}
+		// bootstrap data is small enough to fit into the pipe buffer, so it doesn't block until the plugin reads it
+		_, err = w.Write(dataBytes)
+		if errClose := w.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+		if err != nil {
+			_ = r.Close()
+			return noop, fmt.Errorf("write bootstrap data: %w", err)
+		}
+		command.ExtraFiles = []*os.File{r}
+		command.Env = append(pluginEnv(), plugins.EnvBootstrapFD+"="+strconv.Itoa(bootstrapFD))
+		return func() { _ = r.Close() }, nil
+	default:
+		command.Env = append(pluginEnv(), plugins.EnvSecret+"="+data.Secret)
+		if data.Socket != "" {
+			command.Env = append(command.Env, plugins.EnvSocket+"="+data.Socket)
+		} else {
+			command.Env = append(command.Env, plugins.EnvPort+"="+strconv.Itoa(data.Port))
+		}
+		return noop, nil
+	}
+}
+
+// pluginEnv returns the host environment without bootstrap variables, so they are not inherited
+// when the host itself is run as a plugin.
+func pluginEnv() []string {
+	bootstrapVariables := map[string]struct{}{
+		plugins.EnvSecret:      {},
+		plugins.EnvPort:        {},
+		plugins.EnvSocket:      {},
+		plugins.EnvBootstrapFD: {},
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := bootstrapVariables[name]; ok {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
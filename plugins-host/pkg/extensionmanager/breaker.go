@@ -0,0 +1,262 @@
+package extensionmanager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// BreakerState is a state of the circuit breaker of the plugin extension.
+type BreakerState string
+
+const (
+	// BreakerClosed means that the extension is executed as usual.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen means that the extension is not executed until BreakerConfig.OpenTimeout passes.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen means that a single probe execution decides whether the breaker closes or opens again.
+	BreakerHalfOpen BreakerState = "halfOpen"
+)
+
+// BreakerConfig configures circuit breakers of plugin extensions, see WithCircuitBreaker.
+// Zero fields are replaced by the values of DefaultBreakerConfig.
+type BreakerConfig struct {
+	// Window is the number of the last executions used to calculate the error rate.
+	Window int
+	// MinExecutions is the minimum number of executions in the window required to open the breaker.
+	MinExecutions int
+	// ErrorRate is the rate of failed executions in the window, from 0 to 1, which opens the breaker.
+	ErrorRate float64
+	// OpenTimeout is the time after which the open breaker lets the probe execution through.
+	OpenTimeout time.Duration
+	// FailureCodes are codes of errors counted as failures.
+	// Other errors, e.g. NotFound or InvalidArgument, are considered as the regular extension results.
+	FailureCodes []pluginstypes.Code
+	// SkipWhenOpen makes ExecuteExtensions skip the extension with the open breaker
+	// instead of failing fast with the Unavailable error.
+	SkipWhenOpen bool
+}
+
+// DefaultBreakerConfig is used for zero fields of the BreakerConfig.
+var DefaultBreakerConfig = BreakerConfig{
+	Window:        20,
+	MinExecutions: 5,
+	ErrorRate:     0.5,
+	OpenTimeout:   30 * time.Second,
+	FailureCodes: []pluginstypes.Code{
+		pluginstypes.CodeUnknown,
+		pluginstypes.CodeUnavailable,
+		pluginstypes.CodeDeadlineExceeded,
+		pluginstypes.CodeInternal,
+	},
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.Window <= 0 {
+		c.Window = DefaultBreakerConfig.Window
+	}
+	if c.MinExecutions <= 0 {
+		c.MinExecutions = DefaultBreakerConfig.MinExecutions
+	}
+	if c.ErrorRate <= 0 {
+		c.ErrorRate = DefaultBreakerConfig.ErrorRate
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = DefaultBreakerConfig.OpenTimeout
+	}
+	if len(c.FailureCodes) == 0 {
+		c.FailureCodes = DefaultBreakerConfig.FailureCodes
+	}
+	return c
+}
+
+func (c BreakerConfig) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := pluginstypes.CodeOf(err)
+	for _, failureCode := range c.FailureCodes {
+		if failureCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// BreakerInfo describes the circuit breaker of the plugin extension.
+type BreakerInfo struct {
+	// State is the current state of the breaker.
+	State BreakerState
+	// Executions is the number of executions in the window.
+	Executions int
+	// Failures is the number of failed executions in the window.
+	Failures int
+	// OpenedAt is the time when the breaker was opened last time.
+	OpenedAt time.Time
+}
+
+type breakerKey struct {
+	pluginID    string
+	extensionID string
+}
+
+// circuitBreaker tracks failures of the single plugin extension.
+type circuitBreaker struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex
+	state    BreakerState
+	outcomes []bool
+	next     int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(cfg BreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		cfg:      cfg,
+		state:    BreakerClosed,
+		outcomes: make([]bool, 0, cfg.Window),
+	}
+}
+
+// allow reports whether the extension could be executed now and returns the new state if it is changed.
+func (b *circuitBreaker) allow(now time.Time) (bool, BreakerState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, b.state, false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true, b.state, true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, b.state, false
+		}
+		b.probing = true
+		return true, b.state, false
+	default:
+		return true, b.state, false
+	}
+}
+
+// record registers the result of the execution and returns the new state if it is changed.
+func (b *circuitBreaker) record(failed bool, now time.Time) (BreakerState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		b.outcomes = b.outcomes[:0]
+		b.next = 0
+		if failed {
+			b.state = BreakerOpen
+			b.openedAt = now
+		} else {
+			b.state = BreakerClosed
+		}
+		return b.state, true
+	}
+	if b.state == BreakerOpen {
+		// the execution was started before the breaker opened
+		return b.state, false
+	}
+
+	if len(b.outcomes) < b.cfg.Window {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % b.cfg.Window
+	}
+	executions, failures := b.counts()
+	if executions >= b.cfg.MinExecutions && float64(failures)/float64(executions) >= b.cfg.ErrorRate {
+		b.state = BreakerOpen
+		b.openedAt = now
+		return b.state, true
+	}
+	return b.state, false
+}
+
+func (b *circuitBreaker) counts() (executions int, failures int) {
+	for _, failed := range b.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return len(b.outcomes), failures
+}
+
+func (b *circuitBreaker) info() BreakerInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	executions, failures := b.counts()
+	return BreakerInfo{
+		State:      b.state,
+		Executions: executions,
+		Failures:   failures,
+		OpenedAt:   b.openedAt,
+	}
+}
+
+// WithCircuitBreaker enables circuit breakers for plugin extensions.
+//
+// Every plugin extension gets its own breaker keyed by the plugin ID and the extension ID.
+// When the rate of failed executions reaches the configured one, the breaker opens and the extension
+// is skipped or fails fast with the Unavailable error matching pluginstypes.ErrCircuitOpen.
+// After BreakerConfig.OpenTimeout a single probe execution decides whether the breaker closes.
+// Changes of breakers' states are published as EventBreakerStateChanged.
+func (m *WSManager) WithCircuitBreaker(cfg BreakerConfig) *WSManager {
+	c := cfg.withDefaults()
+	m.breakerConfig = &c
+	return m
+}
+
+// breaker returns the circuit breaker of the plugin extension or nil if breakers are disabled.
+func (m *WSManager) breaker(runtimeInfo extensionRuntimeInfo) *circuitBreaker {
+	if m.breakerConfig == nil || runtimeInfo.conn == nil {
+		return nil
+	}
+	key := breakerKey{pluginID: runtimeInfo.pluginID, extensionID: runtimeInfo.cfg.ID}
+	m.breakersMu.Lock()
+	defer m.breakersMu.Unlock()
+	b, ok := m.breakers[key]
+	if !ok {
+		b = newCircuitBreaker(*m.breakerConfig)
+		m.breakers[key] = b
+	}
+	return b
+}
+
+// breakerInfo returns the state of the circuit breaker of the plugin extension, if it exists.
+func (m *WSManager) breakerInfo(pluginID string, extensionID string) *BreakerInfo {
+	m.breakersMu.Lock()
+	b, ok := m.breakers[breakerKey{pluginID: pluginID, extensionID: extensionID}]
+	m.breakersMu.Unlock()
+	if !ok {
+		return nil
+	}
+	info := b.info()
+	return &info
+}
+
+func (m *WSManager) publishBreakerState(runtimeInfo extensionRuntimeInfo, state BreakerState) {
+	e := Event{
+		Type:             EventBreakerStateChanged,
+		PluginID:         runtimeInfo.pluginID,
+		ExtensionPointID: runtimeInfo.cfg.ExtensionPointID,
+		ExtensionID:      runtimeInfo.cfg.ID,
+		BreakerState:     state,
+	}
+	if state == BreakerOpen {
+		e.Err = fmt.Errorf("plugin %s extension %s: %w", runtimeInfo.pluginID, runtimeInfo.cfg.ID, pluginstypes.ErrCircuitOpen)
+	}
+	m.publish(e)
+}
+
+// newCircuitOpenError returns the error with the Unavailable code matching pluginstypes.ErrCircuitOpen.
+func newCircuitOpenError(runtimeInfo extensionRuntimeInfo) error {
+	return fmt.Errorf("plugin %s extension %s: %w", runtimeInfo.pluginID, runtimeInfo.cfg.ID, pluginstypes.ErrCircuitOpen)
+}
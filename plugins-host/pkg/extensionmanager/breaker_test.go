@@ -0,0 +1,146 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func loadUnhealthyPlugin(ctx context.Context, t *testing.T, m *WSManager, healthy *atomic.Bool, calls *atomic.Int32) {
+	t.Helper()
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.check",
+			ExtensionPointID: "check",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			calls.Add(1)
+			if !healthy.Load() {
+				return "", pluginstypes.NewError(pluginstypes.CodeUnavailable, "database is down")
+			}
+			return "ok", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func executeCheck(ctx context.Context, m *WSManager) (int, error) {
+	count := 0
+	for r := range ExecuteExtensions[string, string](ctx, m, "check", "") {
+		if r.Err != nil {
+			return count, r.Err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func expectBreakerState(t *testing.T, sub *Subscription, state BreakerState) {
+	t.Helper()
+	for {
+		select {
+		case e := <-sub.C():
+			if e.Type != EventBreakerStateChanged {
+				continue
+			}
+			if e.BreakerState != state || e.PluginID != "plugin.A" || e.ExtensionID != "plugina.check" {
+				t.Fatalf("expected breaker state %s, got event %+v", state, e)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatalf("breaker state %s is not published", state)
+		}
+	}
+}
+
+// fakeClock is the clock of circuit breakers advanced by tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithCircuitBreaker(BreakerConfig{
+		Window:        4,
+		MinExecutions: 2,
+		ErrorRate:     0.5,
+		OpenTimeout:   50 * time.Millisecond,
+	})
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	m.now = clock.Now
+	sub := m.Events(0)
+	defer sub.Close()
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	loadUnhealthyPlugin(ctx, t, m, &healthy, &calls)
+
+	for i := 0; i < 2; i++ {
+		if _, err := executeCheck(ctx, m); pluginstypes.CodeOf(err) != pluginstypes.CodeUnavailable {
+			t.Fatalf("expected Unavailable error, got %v", err)
+		}
+	}
+	expectBreakerState(t, sub, BreakerOpen)
+
+	_, err := executeCheck(ctx, m)
+	if !errors.Is(err, pluginstypes.ErrCircuitOpen) || calls.Load() != 2 {
+		t.Fatalf("expected fail fast without execution, got %v after %d calls", err, calls.Load())
+	}
+	breaker := m.Introspect().ExtensionPoints[0].Extensions[0].Breaker
+	if breaker == nil || breaker.State != BreakerOpen {
+		t.Fatalf("expected open breaker in introspection, got %+v", breaker)
+	}
+
+	clock.Advance(49 * time.Millisecond)
+	if _, err := executeCheck(ctx, m); !errors.Is(err, pluginstypes.ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to be open before the timeout, got %v", err)
+	}
+	clock.Advance(time.Millisecond)
+	healthy.Store(true)
+	if n, err := executeCheck(ctx, m); err != nil || n != 1 {
+		t.Fatalf("expected the successful probe, got %d results, %v", n, err)
+	}
+	expectBreakerState(t, sub, BreakerHalfOpen)
+	expectBreakerState(t, sub, BreakerClosed)
+}
+
+func TestCircuitBreakerSkipWhenOpen(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithCircuitBreaker(BreakerConfig{
+		MinExecutions: 1,
+		SkipWhenOpen:  true,
+	})
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	loadUnhealthyPlugin(ctx, t, m, &healthy, &calls)
+
+	if _, err := executeCheck(ctx, m); err == nil {
+		t.Fatal("expected the error")
+	}
+	if n, err := executeCheck(ctx, m); err != nil || n != 0 {
+		t.Fatalf("expected the extension to be skipped, got %d results, %v", n, err)
+	}
+}
@@ -0,0 +1,121 @@
+package extensionmanager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// ErrCardinality is returned by LoadPlugins when the number of extensions of the declared extension point
+// is out of its bounds, see ExtensionPointConfig.
+var ErrCardinality = errors.New("extension point cardinality is violated")
+
+// ExtensionPointConfig declares the extension point, see WithExtensionPoint.
+type ExtensionPointConfig struct {
+	// ID is the ID of the extension point.
+	ID string
+	// MinExtensions is the minimum number of enabled extensions, e.g. 1 if the extension point must be implemented.
+	MinExtensions int
+	// MaxExtensions is the maximum number of enabled extensions, e.g. 1 for the singleton extension point.
+	// Zero means no limit.
+	MaxExtensions int
+	// DefaultExtensionID is the ID of the extension which is executed only if no other extensions are registered,
+	// e.g. the default host implementation which could be overridden by any plugin.
+	DefaultExtensionID string
+}
+
+// WithExtensionPoint declares the extension point with bounds of the number of its extensions
+// and the optional default extension.
+//
+// Bounds are checked when plugins are loaded, LoadPlugins fails with the error matching ErrCardinality
+// if they are violated. When a plugin is unloaded and bounds become violated, EventCardinalityViolated is published.
+func (m *WSManager) WithExtensionPoint(cfg ExtensionPointConfig) *WSManager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.extensionPointConfigByID[cfg.ID] = cfg
+	m.unorderedExtensionPointIDs.Add(cfg.ID)
+	return m
+}
+
+// splitDefault separates the default extension of the extension point if there are other extensions.
+// Extensions disabled by the operator are not counted, see DisableExtension.
+// m.mu should be locked.
+func (m *WSManager) splitDefault(
+	extensionPointID string,
+	infos []extensionRuntimeInfo,
+) ([]extensionRuntimeInfo, []disabledExtension) {
+	defaultID := m.extensionPointConfigByID[extensionPointID].DefaultExtensionID
+	if defaultID == "" {
+		return infos, nil
+	}
+	others := 0
+	for _, info := range infos {
+		if _, operatorDisabled := m.extensionPolicy.DisabledExtensions[info.cfg.ID]; info.cfg.ID != defaultID && !operatorDisabled {
+			others++
+		}
+	}
+	if others == 0 {
+		return infos, nil
+	}
+	var (
+		rest     []extensionRuntimeInfo
+		disabled []disabledExtension
+	)
+	for _, info := range infos {
+		if info.cfg.ID == defaultID {
+			disabled = append(disabled, disabledExtension{
+				info: info,
+				err:  fmt.Errorf(`default extension "%s" is not used as other extensions are registered`, defaultID),
+			})
+		} else {
+			rest = append(rest, info)
+		}
+	}
+	return rest, disabled
+}
+
+// checkCardinalities checks bounds of the number of extensions of all declared extension points.
+// Violations are published as EventCardinalityViolated and returned as the joined error.
+// m.mu should be locked.
+func (m *WSManager) checkCardinalities() error {
+	extensionPointIDs := make([]string, 0, len(m.extensionPointConfigByID))
+	for extensionPointID := range m.extensionPointConfigByID {
+		extensionPointIDs = append(extensionPointIDs, extensionPointID)
+	}
+	sort.Strings(extensionPointIDs)
+
+	var errs []error
+	for _, extensionPointID := range extensionPointIDs {
+		cfg := m.extensionPointConfigByID[extensionPointID]
+		if err := checkCardinality(cfg, len(m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID])); err != nil {
+			m.logger.Error("extension point cardinality is violated", slog.String("err", err.Error()))
+			m.publish(Event{Type: EventCardinalityViolated, ExtensionPointID: extensionPointID, Err: err})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkCardinality(cfg ExtensionPointConfig, extensionsCount int) error {
+	switch {
+	case extensionsCount < cfg.MinExtensions:
+		return fmt.Errorf(
+			`extension point "%s" requires at least %d extensions, but %d are registered: %w`,
+			cfg.ID,
+			cfg.MinExtensions,
+			extensionsCount,
+			ErrCardinality,
+		)
+	case cfg.MaxExtensions > 0 && extensionsCount > cfg.MaxExtensions:
+		return fmt.Errorf(
+			`extension point "%s" allows at most %d extensions, but %d are registered: %w`,
+			cfg.ID,
+			cfg.MaxExtensions,
+			extensionsCount,
+			ErrCardinality,
+		)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func storagePlugin(ids ...string) func(p *plugins.Plugin) {
+	return func(p *plugins.Plugin) {
+		for _, id := range ids {
+			id := id
+			p.Extension(pluginstypes.ExtensionConfig{
+				ID:               id,
+				ExtensionPointID: "storage",
+			}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+				return id, nil
+			}))
+		}
+	}
+}
+
+func executedIDs(ctx context.Context, t *testing.T, m *WSManager) string {
+	t.Helper()
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "storage", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	return fmt.Sprint(outs)
+}
+
+func TestExtensionPointCardinality(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithExtensionPoint(ExtensionPointConfig{ID: "storage", MinExtensions: 1, MaxExtensions: 1})
+	if err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {}); !errors.Is(err, ErrCardinality) {
+		t.Fatalf("expected ErrCardinality for the missing extension, got %v", err)
+	}
+
+	m = NewWSManager().WithExtensionPoint(ExtensionPointConfig{ID: "storage", MinExtensions: 1, MaxExtensions: 1})
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage", "a.storage2")); !errors.Is(err, ErrCardinality) {
+		t.Fatalf("expected ErrCardinality for the redundant extension, got %v", err)
+	}
+
+	m = NewWSManager().WithExtensionPoint(ExtensionPointConfig{ID: "storage", MinExtensions: 1})
+	sub := m.Events(0)
+	defer sub.Close()
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage")); err != nil {
+		t.Fatal(err)
+	}
+	m.unregisterPlugin("plugin.A", m.channelByPluginID["plugin.A"])
+	for e := range sub.C() {
+		if e.Type == EventCardinalityViolated {
+			if e.ExtensionPointID != "storage" || !errors.Is(e.Err, ErrCardinality) {
+				t.Fatalf("unexpected event %+v", e)
+			}
+			break
+		}
+	}
+}
+
+func TestExtensionPointDefault(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithExtensionPoint(ExtensionPointConfig{
+		ID:                 "storage",
+		MinExtensions:      1,
+		MaxExtensions:      1,
+		DefaultExtensionID: "app.storage",
+	})
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.storage",
+		ExtensionPointID: "storage",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "app.storage", nil
+	})
+
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage")); err != nil {
+		t.Fatal(err)
+	}
+	if got := executedIDs(ctx, t, m); got != "[a.storage]" {
+		t.Fatalf("default extension should not be executed, got %s", got)
+	}
+
+	if err := m.DisableExtension("a.storage", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := executedIDs(ctx, t, m); got != "[app.storage]" {
+		t.Fatalf("default extension should be executed when other extensions are disabled, got %s", got)
+	}
+	if err := m.EnableExtension("a.storage"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.unregisterPlugin("plugin.A", m.channelByPluginID["plugin.A"])
+	if got := executedIDs(ctx, t, m); got != "[app.storage]" {
+		t.Fatalf("default extension should be executed when plugin is unloaded, got %s", got)
+	}
+}
+
+func TestCardinalityCheckedOnRegistrationAfterLoading(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithExtensionPoint(ExtensionPointConfig{ID: "storage", MinExtensions: 1, MaxExtensions: 1})
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage")); err != nil {
+		t.Fatal(err)
+	}
+	sub := m.Events(16)
+	defer sub.Close()
+
+	// the plugin connects by itself, e.g. after the reconnection, without LoadPlugins awaiting it
+	connectPlugin(ctx, m, "plugin.B", storagePlugin("b.storage"))
+
+	for {
+		select {
+		case e := <-sub.C():
+			if e.Type != EventCardinalityViolated {
+				continue
+			}
+			if e.ExtensionPointID != "storage" || !errors.Is(e.Err, ErrCardinality) {
+				t.Fatalf("unexpected event %+v", e)
+			}
+			if got := executedIDs(ctx, t, m); got != "[a.storage b.storage]" {
+				t.Fatalf("extensions of the registered plugin should be ordered, got %s", got)
+			}
+			if err := m.Shutdown(ctx); err != nil {
+				t.Fatal(err)
+			}
+			// all plugins are unregistered during the shutdown, but violations are not reported
+			for {
+				select {
+				case e := <-sub.C():
+					if e.Type == EventCardinalityViolated {
+						t.Fatalf("unexpected violation during the shutdown %+v", e)
+					}
+				default:
+					return
+				}
+			}
+		case <-ctx.Done():
+			t.Fatal("cardinality violation is not published")
+		}
+	}
+}
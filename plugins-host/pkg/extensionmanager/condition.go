@@ -0,0 +1,64 @@
+package extensionmanager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// conditionInput evaluates conditions of extensions over the execution input.
+// The input is converted to JSON once, when the first extension with the condition is executed.
+type conditionInput struct {
+	in        any
+	values    map[string]string
+	err       error
+	converted bool
+}
+
+// matches reports whether the condition of the extension matches the input.
+// Extensions without conditions match any input.
+func (c *conditionInput) matches(info extensionRuntimeInfo) (bool, error) {
+	if info.condition == nil {
+		return true, nil
+	}
+	if !c.converted {
+		c.converted = true
+		var inBytes []byte
+		if inBytes, c.err = json.Marshal(c.in); c.err == nil {
+			c.values, c.err = pluginstypes.ConditionValues(inBytes)
+		}
+	}
+	if c.err != nil {
+		return false, fmt.Errorf("evaluate condition of extension %s: %w", info.cfg.ID, c.err)
+	}
+	return info.condition.Matches(c.values), nil
+}
+
+// splitInvalidConditions parses conditions of extensions and separates extensions which conditions are invalid.
+func splitInvalidConditions(infos []extensionRuntimeInfo) ([]extensionRuntimeInfo, []disabledExtension) {
+	var (
+		valid   = make([]extensionRuntimeInfo, 0, len(infos))
+		invalid []disabledExtension
+	)
+	for _, info := range infos {
+		if info.cfg.Condition != "" {
+			condition, err := pluginstypes.ParseCondition(info.cfg.Condition)
+			if err != nil {
+				invalid = append(invalid, disabledExtension{info: info, err: fmt.Errorf("extension %s: %w", info.cfg.ID, err)})
+				continue
+			}
+			info.condition = condition
+		}
+		valid = append(valid, info)
+	}
+	return valid, invalid
+}
+
+// skippedMeta describes the extension skipped because its condition doesn't match the input.
+func skippedMeta(info extensionRuntimeInfo) pluginstypes.ResultMeta {
+	meta := newResultMeta(info)
+	meta.Skipped = true
+	meta.SkipReason = fmt.Sprintf("condition %q doesn't match the input", info.cfg.Condition)
+	return meta
+}
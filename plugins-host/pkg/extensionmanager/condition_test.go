@@ -0,0 +1,86 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+type formatInput struct {
+	Path string `json:"path"`
+}
+
+func TestExecuteWithConditions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	m := NewWSManager()
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		for _, cfg := range []pluginstypes.ExtensionConfig{
+			{ID: "fmt.sql", Condition: "path~*.sql"},
+			{ID: "fmt.go", Condition: "path~*.go"},
+			{ID: "fmt.generic", FallbackFor: "fmt.go"},
+		} {
+			cfg := cfg
+			cfg.ExtensionPointID = "fmt"
+			p.Extension(cfg, plugins.Implementation(func(ctx context.Context, in formatInput) (string, error) {
+				calls.Add(1)
+				return cfg.ID, nil
+			}))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execute := func(path string) string {
+		var res []string
+		for r := range ExecuteExtensions[formatInput, string](ctx, m, "fmt", formatInput{Path: path}) {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			if r.Meta.Skipped {
+				res = append(res, "skipped:"+r.Meta.ExtensionID)
+				continue
+			}
+			res = append(res, r.Out)
+		}
+		return fmt.Sprint(res)
+	}
+
+	// fallbacks are executed only if the primary extension fails, not if it is skipped
+	if res := execute("db/001.sql"); res != "[skipped:fmt.go fmt.sql]" {
+		t.Fatalf("unexpected results %s", res)
+	}
+	if res := execute("main.go"); res != "[fmt.go skipped:fmt.sql]" {
+		t.Fatalf("unexpected results %s", res)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 executed extensions, got %d", calls.Load())
+	}
+
+	sub := m.Events(16)
+	defer sub.Close()
+	Extension(m, pluginstypes.ExtensionConfig{ID: "fmt.broken", ExtensionPointID: "fmt", Condition: "path in *.sql"},
+		func(ctx context.Context, in formatInput) (string, error) {
+			return "", nil
+		})
+	select {
+	case e := <-sub.C():
+		if e.Type != EventExtensionDisabled || e.ExtensionID != "fmt.broken" || !errors.Is(e.Err, pluginstypes.ErrInvalidCondition) {
+			t.Fatalf("extension with the invalid condition should be disabled, got %+v", e)
+		}
+	default:
+		t.Fatal("extension with the invalid condition is not disabled during the registration")
+	}
+	if res := execute("main.go"); res != "[fmt.go skipped:fmt.sql]" {
+		t.Fatalf("unexpected results %s", res)
+	}
+}
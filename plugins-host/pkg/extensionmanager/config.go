@@ -0,0 +1,103 @@
+package extensionmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+	"gopkg.in/yaml.v3"
+)
+
+// WithPluginConfig sets the configuration delivered to the plugin with the given ID
+// in the registration acknowledgement.
+func (m *WSManager) WithPluginConfig(pluginID string, cfg json.RawMessage) *WSManager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pluginConfigByPluginID[pluginID] = cfg
+	return m
+}
+
+// LoadPluginConfigFile loads plugins' configuration from the YAML file.
+// Each top-level key of the file is a plugin ID (Signature.ID) and its value is the plugin configuration, e.g.:
+//
+//	plugin.A:
+//	  endpoint: http://localhost:8080
+//	  features:
+//	    experimental: true
+//
+// The configuration should be loaded before plugins to be delivered during their registration.
+func (m *WSManager) LoadPluginConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read plugins config file: %w", err)
+	}
+	configs, err := parsePluginConfigs(data)
+	if err != nil {
+		return fmt.Errorf("parse plugins config file %s: %w", path, err)
+	}
+	for pluginID, cfg := range configs {
+		m.WithPluginConfig(pluginID, cfg)
+	}
+	return nil
+}
+
+// UpdatePluginConfig replaces the configuration of the plugin and pushes it to the plugin if it is connected.
+func (m *WSManager) UpdatePluginConfig(pluginID string, cfg json.RawMessage) error {
+	m.mu.Lock()
+	m.pluginConfigByPluginID[pluginID] = cfg
+	c, ok := m.channelByPluginID[pluginID]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(pluginstypes.ConfigChangedData{Config: cfg})
+	if err != nil {
+		return fmt.Errorf("marshal plugin config: %w", err)
+	}
+	msg := pluginstypes.Message{
+		Type:    pluginstypes.CommandTypeConfigChanged,
+		Data:    data,
+		IsFinal: true,
+	}
+	if err := m.writeResponse(msg, c); err != nil {
+		return fmt.Errorf("push config to plugin %s: %w", pluginID, err)
+	}
+	return nil
+}
+
+func (m *WSManager) sendRegistrationAck(registerMsg pluginstypes.Message, pluginID string, c transport.Conn) error {
+	m.mu.Lock()
+	cfg := m.pluginConfigByPluginID[pluginID]
+	m.mu.Unlock()
+
+	data, err := json.Marshal(pluginstypes.RegisterPluginAckData{Config: cfg})
+	if err != nil {
+		return fmt.Errorf("marshal registration acknowledgement: %w", err)
+	}
+	msg := pluginstypes.Message{
+		Type:          pluginstypes.CommandTypeRegisterPluginAck,
+		CorrelationID: registerMsg.MsgID,
+		Data:          data,
+		IsFinal:       true,
+	}
+	return m.writeResponse(msg, c)
+}
+
+func parsePluginConfigs(data []byte) (map[string]json.RawMessage, error) {
+	var sections map[string]any
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+	configs := make(map[string]json.RawMessage, len(sections))
+	for pluginID, section := range sections {
+		cfg, err := json.Marshal(section)
+		if err != nil {
+			return nil, fmt.Errorf("convert config of the plugin %s to JSON: %w", pluginID, err)
+		}
+		configs[pluginID] = cfg
+	}
+	return configs, nil
+}
@@ -0,0 +1,88 @@
+package extensionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+type testPluginConfig struct {
+	Endpoint string          `json:"endpoint"`
+	Features map[string]bool `json:"features"`
+}
+
+func TestPluginConfigDelivery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	configs, err := parsePluginConfigs([]byte(`
+plugin.A:
+  endpoint: http://localhost:8080
+  features:
+    experimental: true
+plugin.B:
+  endpoint: http://localhost:9090
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewWSManager()
+	for pluginID, cfg := range configs {
+		m.WithPluginConfig(pluginID, cfg)
+	}
+
+	var p *plugins.Plugin
+	changed := make(chan testPluginConfig, 1)
+	err = m.LoadInProcess(ctx, "plugin.A", func(plugin *plugins.Plugin) {
+		p = plugin
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.endpoint",
+			ExtensionPointID: "endpoint",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			cfg, err := plugins.ConfigOf[testPluginConfig](p)
+			return cfg.Endpoint, err
+		}))
+		plugins.OnConfigChangeOf[testPluginConfig](p, func(ctx context.Context, cfg testPluginConfig, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			changed <- cfg
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for r := range ExecuteExtensions[string, string](ctx, m, "endpoint", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Out != "http://localhost:8080" {
+			t.Fatalf("unexpected endpoint %s", r.Out)
+		}
+	}
+	cfg, err := plugins.ConfigOf[testPluginConfig](p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Features["experimental"] {
+		t.Fatalf("experimental feature should be enabled: %+v", cfg)
+	}
+
+	if err := m.UpdatePluginConfig("plugin.A", json.RawMessage(`{"endpoint":"http://localhost:8081"}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-changed:
+		if cfg.Endpoint != "http://localhost:8081" {
+			t.Fatalf("unexpected changed config %+v", cfg)
+		}
+	case <-ctx.Done():
+		t.Fatal("config change was not delivered")
+	}
+}
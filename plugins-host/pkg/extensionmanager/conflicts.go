@@ -0,0 +1,203 @@
+package extensionmanager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// ErrConflict is returned by LoadPlugins when conflicting extensions or plugins are registered
+// and the ConflictFail policy is used.
+var ErrConflict = errors.New("conflicting extensions are registered")
+
+// ConflictPolicy defines how conflicts declared via ExtensionConfig.ConflictsWith
+// and plugins.Plugin.ConflictsWith are resolved.
+type ConflictPolicy string
+
+const (
+	// ConflictFail makes LoadPlugins fail with ErrConflict. It is the default policy.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictDropLower drops the lower-priority side of the conflict and publishes EventExtensionDisabled.
+	// For conflicting extensions the one executed earlier without constraints wins, see ExtensionConfig.Priority.
+	// For conflicting plugins the earlier loaded plugin wins.
+	// Dropped extensions are restored when the winning side is unregistered.
+	ConflictDropLower ConflictPolicy = "dropLower"
+)
+
+// Conflict describes the resolved conflict between extensions of the extension point.
+type Conflict struct {
+	// Winner is the ID of the kept extension.
+	Winner string
+	// Loser is the ID of the dropped extension.
+	Loser string
+	// WinnerPluginID and LoserPluginID are set if the conflict is declared between plugins.
+	WinnerPluginID string
+	LoserPluginID  string
+}
+
+// WithConflictPolicy sets the policy applied to conflicting extensions and plugins.
+func (m *WSManager) WithConflictPolicy(policy ConflictPolicy) *WSManager {
+	m.conflictPolicy = policy
+	return m
+}
+
+// resolveConflicts drops extensions of losing plugins and then extensions conflicting with preceding ones.
+// m.mu should be locked.
+func (m *WSManager) resolveConflicts(infos []extensionRuntimeInfo) (
+	kept []extensionRuntimeInfo,
+	dropped []disabledExtension,
+	conflicts []Conflict,
+	err error,
+) {
+	winnerByLoserPluginID, err := m.resolvePluginConflicts()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sorted := make([]extensionRuntimeInfo, len(infos))
+	copy(sorted, infos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return precedes(sorted[i], sorted[j])
+	})
+	for _, info := range sorted {
+		if winnerPluginID, ok := winnerByLoserPluginID[info.pluginID]; ok {
+			dropped = append(dropped, disabledExtension{
+				info: info,
+				err: fmt.Errorf(
+					`extension "%s" is dropped as plugin "%s" conflicts with plugin "%s": %w`,
+					info.cfg.ID,
+					info.pluginID,
+					winnerPluginID,
+					ErrConflict,
+				),
+			})
+			conflicts = append(conflicts, Conflict{
+				Loser:          info.cfg.ID,
+				WinnerPluginID: winnerPluginID,
+				LoserPluginID:  info.pluginID,
+			})
+			continue
+		}
+
+		var winner *extensionRuntimeInfo
+		for i := range kept {
+			if extensionsConflict(kept[i], info) {
+				winner = &kept[i]
+				break
+			}
+		}
+		if winner == nil {
+			kept = append(kept, info)
+			continue
+		}
+		conflictErr := fmt.Errorf(
+			`extension "%s" of %s conflicts with extension "%s" of %s: %w`,
+			info.cfg.ID,
+			describePlugin(info.pluginID),
+			winner.cfg.ID,
+			describePlugin(winner.pluginID),
+			ErrConflict,
+		)
+		if m.conflictPolicy != ConflictDropLower {
+			return nil, nil, nil, conflictErr
+		}
+		dropped = append(dropped, disabledExtension{info: info, err: conflictErr})
+		conflicts = append(conflicts, Conflict{Winner: winner.cfg.ID, Loser: info.cfg.ID})
+	}
+
+	// keep the original order for the stable tie-breaking of further steps
+	keptIDs := NewSet[string]()
+	for _, info := range kept {
+		keptIDs.Add(info.cfg.ID)
+	}
+	kept = kept[:0]
+	for _, info := range infos {
+		if keptIDs.Contains(info.cfg.ID) {
+			kept = append(kept, info)
+		}
+	}
+	return kept, dropped, conflicts, nil
+}
+
+func extensionsConflict(a extensionRuntimeInfo, b extensionRuntimeInfo) bool {
+	return slices.Contains(a.cfg.ConflictsWith, b.cfg.ID) || slices.Contains(b.cfg.ConflictsWith, a.cfg.ID)
+}
+
+// resolvePluginConflicts returns IDs of winning plugins by IDs of losing ones among registered plugins.
+// m.mu should be locked.
+func (m *WSManager) resolvePluginConflicts() (map[string]string, error) {
+	var winnerByLoserPluginID map[string]string
+	pluginIDs := make([]string, 0, len(m.conflictsByPluginID))
+	for pluginID := range m.conflictsByPluginID {
+		pluginIDs = append(pluginIDs, pluginID)
+	}
+	sort.Strings(pluginIDs)
+	for _, pluginID := range pluginIDs {
+		if _, ok := m.channelByPluginID[pluginID]; !ok {
+			continue
+		}
+		for _, otherPluginID := range m.conflictsByPluginID[pluginID] {
+			if _, ok := m.channelByPluginID[otherPluginID]; !ok || otherPluginID == pluginID {
+				continue
+			}
+			if m.conflictPolicy != ConflictDropLower {
+				return nil, fmt.Errorf(`plugin "%s" conflicts with plugin "%s": %w`, pluginID, otherPluginID, ErrConflict)
+			}
+			winner, loser := pluginID, otherPluginID
+			if m.pluginLoadIndex(loser) < m.pluginLoadIndex(winner) {
+				winner, loser = loser, winner
+			}
+			if winnerByLoserPluginID == nil {
+				winnerByLoserPluginID = make(map[string]string)
+			}
+			winnerByLoserPluginID[loser] = winner
+		}
+	}
+	return winnerByLoserPluginID, nil
+}
+
+// pluginLoadIndex returns the load index of the registered plugin, see loadIndexByPluginID.
+// m.mu should be locked.
+func (m *WSManager) pluginLoadIndex(pluginID string) int {
+	return m.loadIndexByPluginID[pluginID]
+}
+
+// detectConflicts publishes EventConflictDetected for conflicts between the registering plugin and registered ones.
+// Conflicts are resolved according to the policy when extensions are ordered.
+// m.mu should be locked.
+func (m *WSManager) detectConflicts(registerData pluginstypes.RegisterPluginData) {
+	pluginID := registerData.PluginID
+	var errs []error
+	for otherPluginID := range m.channelByPluginID {
+		if otherPluginID != pluginID &&
+			(slices.Contains(registerData.ConflictsWith, otherPluginID) || slices.Contains(m.conflictsByPluginID[otherPluginID], pluginID)) {
+			errs = append(errs, fmt.Errorf(`plugin "%s" conflicts with plugin "%s": %w`, pluginID, otherPluginID, ErrConflict))
+		}
+	}
+	for _, cfg := range registerData.Extensions {
+		registering := extensionRuntimeInfo{pluginID: pluginID, cfg: cfg}
+		for _, info := range m.extensionRuntimeInfoByExtensionPointIDs[cfg.ExtensionPointID] {
+			if info.pluginID != pluginID && extensionsConflict(registering, info) {
+				errs = append(errs, fmt.Errorf(
+					`extension "%s" of %s conflicts with extension "%s" of %s: %w`,
+					cfg.ID,
+					describePlugin(pluginID),
+					info.cfg.ID,
+					describePlugin(info.pluginID),
+					ErrConflict,
+				))
+			}
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	for _, err := range errs {
+		m.logger.Warn("conflict detected", slog.String("pluginID", pluginID), slog.String("err", err.Error()))
+		m.publish(Event{Type: EventConflictDetected, PluginID: pluginID, Err: err})
+	}
+}
@@ -0,0 +1,141 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func formatterPlugin(id string, priority int, conflictsWith ...string) func(p *plugins.Plugin) {
+	return func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               id,
+			ExtensionPointID: "format",
+			Priority:         priority,
+			ConflictsWith:    conflictsWith,
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return id, nil
+		}))
+	}
+}
+
+func executedFormatters(ctx context.Context, t *testing.T, m *WSManager) string {
+	t.Helper()
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "format", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	return fmt.Sprint(outs)
+}
+
+func TestExtensionConflicts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	if err := m.LoadInProcess(ctx, "plugin.A", formatterPlugin("a.fmt", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.LoadInProcess(ctx, "plugin.B", formatterPlugin("b.fmt", 1, "a.fmt")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	m = NewWSManager().WithConflictPolicy(ConflictDropLower)
+	if err := m.LoadInProcess(ctx, "plugin.A", formatterPlugin("a.fmt", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.LoadInProcess(ctx, "plugin.B", formatterPlugin("b.fmt", 1, "a.fmt")); err != nil {
+		t.Fatal(err)
+	}
+	if got := executedFormatters(ctx, t, m); got != "[b.fmt]" {
+		t.Fatalf("higher priority extension should win, got %s", got)
+	}
+	e := m.ExplainOrder()[0]
+	if fmt.Sprint(e.Conflicts) != fmt.Sprint([]Conflict{{Winner: "b.fmt", Loser: "a.fmt"}}) {
+		t.Fatalf("unexpected conflicts %+v", e.Conflicts)
+	}
+	if !strings.Contains(e.Mermaid(), "e1 -.->|conflict| c2") {
+		t.Fatalf("unexpected Mermaid:\n%s", e.Mermaid())
+	}
+}
+
+func TestPluginConflicts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithConflictPolicy(ConflictDropLower)
+	if err := m.LoadInProcess(ctx, "plugin.A", formatterPlugin("a.fmt", 0)); err != nil {
+		t.Fatal(err)
+	}
+	err := m.LoadInProcess(ctx, "plugin.B", func(p *plugins.Plugin) {
+		p.ConflictsWith("plugin.A")
+		formatterPlugin("b.fmt", 1)(p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executedFormatters(ctx, t, m); got != "[a.fmt]" {
+		t.Fatalf("earlier loaded plugin should win, got %s", got)
+	}
+	e := m.ExplainOrder()[0]
+	if len(e.Conflicts) != 1 || e.Conflicts[0].WinnerPluginID != "plugin.A" || e.Conflicts[0].LoserPluginID != "plugin.B" {
+		t.Fatalf("unexpected conflicts %+v", e.Conflicts)
+	}
+}
+
+func TestPluginConflictWinnerIsStable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithConflictPolicy(ConflictDropLower)
+	if err := m.LoadInProcess(ctx, "plugin.A", formatterPlugin("a.fmt", 0)); err != nil {
+		t.Fatal(err)
+	}
+	sub := m.Events(16)
+	defer sub.Close()
+	err := m.LoadInProcess(ctx, "plugin.B", func(p *plugins.Plugin) {
+		p.ConflictsWith("plugin.A")
+		formatterPlugin("b.fmt", 1)(p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-sub.C():
+		if e.Type != EventConflictDetected || e.PluginID != "plugin.B" || !errors.Is(e.Err, ErrConflict) {
+			t.Fatalf("expected the conflict detected during the registration, got %+v", e)
+		}
+	default:
+		t.Fatal("conflict is not detected during the registration")
+	}
+
+	// the restarted plugin keeps its load index, so it still wins
+	m.unregisterPlugin("plugin.A", m.channelByPluginID["plugin.A"])
+	connectPlugin(ctx, m, "plugin.A", formatterPlugin("a.fmt", 0))
+	for e := range sub.C() {
+		if e.Type == EventPluginRestarted {
+			break
+		}
+	}
+	for i := 0; i < 10; i++ {
+		m.mu.Lock()
+		m.unorderedExtensionPointIDs.Add("format")
+		err := m.reorderChangedExtensionPoints()
+		m.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := executedFormatters(ctx, t, m); got != "[a.fmt]" {
+			t.Fatalf("earlier loaded plugin should win after its restart, got %s", got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package extensionmanager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrMissingDependency is returned by LoadPlugins when the extension required via RequiresExtensionIDs
+// is not registered for the same extension point.
+var ErrMissingDependency = errors.New("required extension is not registered")
+
+// MissingDependencyPolicy defines what happens with the extension which required extension is not registered.
+type MissingDependencyPolicy string
+
+const (
+	// MissingDependencyFail makes LoadPlugins fail with ErrMissingDependency. It is the default policy.
+	MissingDependencyFail MissingDependencyPolicy = "fail"
+	// MissingDependencyDisable disables the extension and publishes EventExtensionDisabled.
+	// The extension is enabled again when the required extension is registered by plugins loaded later.
+	MissingDependencyDisable MissingDependencyPolicy = "disable"
+)
+
+// disabledExtension is the registered extension which is not executed.
+type disabledExtension struct {
+	info extensionRuntimeInfo
+	err  error
+}
+
+// WithMissingDependencyPolicy sets the policy applied to extensions which required extensions are not registered.
+func (m *WSManager) WithMissingDependencyPolicy(policy MissingDependencyPolicy) *WSManager {
+	m.missingDependencyPolicy = policy
+	return m
+}
+
+// checkRequiredExtensions separates extensions which required extensions are missing.
+// Disabling the extension could make its dependants disabled too, so the check is repeated until nothing changes.
+// If disable is false, the first missing dependency is returned as the error.
+func checkRequiredExtensions(
+	infos []extensionRuntimeInfo,
+	disable bool,
+) (enabled []extensionRuntimeInfo, disabled []disabledExtension, err error) {
+	enabled = infos
+	for {
+		extensionIDs := NewSet[string]()
+		for _, info := range enabled {
+			extensionIDs.Add(info.cfg.ID)
+			if info.cfg.FallbackFor != "" {
+				// the fallback of the missing primary extension replaces it
+				extensionIDs.Add(info.cfg.FallbackFor)
+			}
+			if info.cfg.Replaces != "" {
+				extensionIDs.Add(info.cfg.Replaces)
+			}
+		}
+
+		var satisfied []extensionRuntimeInfo
+		for _, info := range enabled {
+			missingErr := missingDependencyError(info, extensionIDs)
+			switch {
+			case missingErr == nil:
+				satisfied = append(satisfied, info)
+			case !disable:
+				return nil, nil, missingErr
+			default:
+				disabled = append(disabled, disabledExtension{info: info, err: missingErr})
+			}
+		}
+		if len(satisfied) == len(enabled) {
+			return enabled, disabled, nil
+		}
+		enabled = satisfied
+	}
+}
+
+func missingDependencyError(info extensionRuntimeInfo, extensionIDs *Set[string]) error {
+	for _, requiredID := range info.cfg.RequiresExtensionIDs {
+		if !extensionIDs.Contains(requiredID) {
+			pluginID := info.pluginID
+			if pluginID == "" {
+				pluginID = "host"
+			}
+			return fmt.Errorf(
+				`extension "%s" declared by plugin "%s" requires extension "%s" of the extension point "%s": %w`,
+				info.cfg.ID,
+				pluginID,
+				requiredID,
+				info.cfg.ExtensionPointID,
+				ErrMissingDependency,
+			)
+		}
+	}
+	return nil
+}
+
+// resolveExtensionPoint disables extensions with invalid conditions and missing dependencies according to the policy
+// and orders the rest ones.
+// Extensions disabled previously are checked again, as their dependencies could be registered since then.
+// m.mu should be locked.
+func (m *WSManager) resolveExtensionPoint(
+	extensionPointID string,
+	infos []extensionRuntimeInfo,
+) ([]extensionRuntimeInfo, error) {
+	all := make([]extensionRuntimeInfo, 0, len(infos)+len(m.disabledExtensionsByExtensionPointID[extensionPointID]))
+	all = append(all, infos...)
+	for _, d := range m.disabledExtensionsByExtensionPointID[extensionPointID] {
+		all = append(all, d.info)
+	}
+	all, invalid := splitInvalidConditions(all)
+	kept, replaced, replacerIDs, err := splitReplaced(all)
+	if err != nil {
+		m.publish(Event{Type: EventOrderingError, ExtensionPointID: extensionPointID, Err: err})
+		return nil, err
+	}
+	kept, dropped, conflicts, err := m.resolveConflicts(kept)
+	if err != nil {
+		m.publish(Event{Type: EventOrderingError, ExtensionPointID: extensionPointID, Err: err})
+		return nil, err
+	}
+	enabled, disabled, err := checkRequiredExtensions(kept, m.missingDependencyPolicy == MissingDependencyDisable)
+	if err != nil {
+		m.publish(Event{Type: EventOrderingError, ExtensionPointID: extensionPointID, Err: err})
+		return nil, err
+	}
+	disabled = append(append(invalid, dropped...), disabled...)
+	enabled, defaults := m.splitDefault(extensionPointID, enabled)
+	// replaced extensions are passed to keep their constraints applied to the replacing ones
+	ordered, err := orderReplaced(enabled, replaced, replacerIDs)
+	if err != nil {
+		m.publish(Event{Type: EventOrderingError, ExtensionPointID: extensionPointID, Err: err})
+		return nil, err
+	}
+	// extensions disabled by the operator are removed after ordering to keep constraints declared through them
+	ordered, operatorDisabled := m.splitOperatorDisabled(ordered)
+	disabled = append(disabled, operatorDisabled...)
+
+	wasDisabled := NewSet[string]()
+	for _, d := range m.disabledExtensionsByExtensionPointID[extensionPointID] {
+		wasDisabled.Add(d.info.cfg.ID)
+	}
+	for _, d := range disabled {
+		if !wasDisabled.Contains(d.info.cfg.ID) {
+			m.logger.Warn("extension is disabled", slog.String("err", d.err.Error()))
+			m.publish(Event{
+				Type:             EventExtensionDisabled,
+				PluginID:         d.info.pluginID,
+				ExtensionPointID: extensionPointID,
+				ExtensionID:      d.info.cfg.ID,
+				Err:              d.err,
+			})
+		}
+	}
+	// default and replaced extensions are kept to be restored when other extensions are unregistered
+	disabled = append(disabled, defaults...)
+	for _, info := range replaced {
+		disabled = append(disabled, disabledExtension{
+			info: info,
+			err:  fmt.Errorf(`extension "%s" is replaced by extension "%s"`, info.cfg.ID, replacerIDs[info.cfg.ID]),
+		})
+	}
+	if len(disabled) > 0 {
+		m.disabledExtensionsByExtensionPointID[extensionPointID] = disabled
+	} else {
+		delete(m.disabledExtensionsByExtensionPointID, extensionPointID)
+	}
+	if len(conflicts) > 0 {
+		m.conflictsByExtensionPointID[extensionPointID] = conflicts
+	} else {
+		delete(m.conflictsByExtensionPointID, extensionPointID)
+	}
+	return ordered, nil
+}
@@ -0,0 +1,122 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func requiringPlugin(p *plugins.Plugin) {
+	p.Extension(pluginstypes.ExtensionConfig{
+		ID:                   "plugina.hello",
+		ExtensionPointID:     "hello",
+		RequiresExtensionIDs: []string{"pluginb.hello"},
+	}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+		return "a", nil
+	}))
+}
+
+func TestMissingDependencyFail(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := NewWSManager().LoadInProcess(ctx, "plugin.A", requiringPlugin)
+	if !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("expected ErrMissingDependency, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"pluginb.hello"`) || !strings.Contains(err.Error(), `"plugin.A"`) {
+		t.Fatalf("error should name the missing extension and the plugin: %v", err)
+	}
+}
+
+func TestMissingDependencyDisable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager().WithMissingDependencyPolicy(MissingDependencyDisable)
+	sub := m.Events(0)
+	defer sub.Close()
+
+	if err := m.LoadInProcess(ctx, "plugin.A", requiringPlugin); err != nil {
+		t.Fatal(err)
+	}
+	for e := range sub.C() {
+		if e.Type == EventExtensionDisabled {
+			if e.ExtensionID != "plugina.hello" || !errors.Is(e.Err, ErrMissingDependency) {
+				t.Fatalf("unexpected event %+v", e)
+			}
+			break
+		}
+	}
+	for r := range ExecuteExtensions[string, string](ctx, m, "hello", "") {
+		t.Fatalf("disabled extension is executed: %+v", r)
+	}
+
+	err := m.LoadInProcess(ctx, "plugin.B", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "pluginb.hello",
+			ExtensionPointID: "hello",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "b", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "hello", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	if strings.Join(outs, ",") != "b,a" {
+		t.Fatalf("extension should be enabled after its dependency, got %v", outs)
+	}
+}
+
+func TestMissingDependencyOnLateRegistration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage")); err != nil {
+		t.Fatal(err)
+	}
+	sub := m.Events(16)
+	defer sub.Close()
+
+	// the plugin connects by itself, so it is ordered on its registration
+	connectPlugin(ctx, m, "plugin.B", func(p *plugins.Plugin) {
+		requiringPlugin(p)
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:                 "b.storage",
+			ExtensionPointID:   "storage",
+			BeforeExtensionIDs: []string{"a.storage"},
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "b.storage", nil
+		}))
+	})
+	for e := range sub.C() {
+		if e.Type == EventPluginRegistered && e.PluginID == "plugin.B" {
+			break
+		}
+	}
+
+	var got []pluginstypes.ExecuteExtensionResult[string]
+	for r := range ExecuteExtensions[string, string](ctx, m, "hello", "") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || !errors.Is(got[0].Err, ErrMissingDependency) {
+		t.Fatalf("extension point which can't be ordered should fail with ErrMissingDependency, got %+v", got)
+	}
+	// other changed extension points are ordered regardless of the failed one
+	if got := executedIDs(ctx, t, m); got != "[b.storage a.storage]" {
+		t.Fatalf("extensions of the registered plugin should be ordered, got %s", got)
+	}
+}
@@ -0,0 +1,57 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+func init() {
+	if err := pluginstypes.RegisterError("test.quotaExceeded", errQuotaExceeded); err != nil {
+		panic(err)
+	}
+}
+
+func TestPluginErrorsMatchOnHost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.quota",
+			ExtensionPointID: "quota",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "", fmt.Errorf("check %s: %w", in, errQuotaExceeded)
+		}))
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.user",
+			ExtensionPointID: "user",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "", pluginstypes.NewError(pluginstypes.CodeNotFound, "user "+in).WithDetail("user", in)
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for r := range ExecuteExtensions[string, string](ctx, m, "quota", "Anton") {
+		if !errors.Is(r.Err, errQuotaExceeded) {
+			t.Fatalf("expected %v to match %v", r.Err, errQuotaExceeded)
+		}
+	}
+	for r := range ExecuteExtensions[string, string](ctx, m, "user", "Anton") {
+		var pluginErr *pluginstypes.PluginError
+		if pluginstypes.CodeOf(r.Err) != pluginstypes.CodeNotFound ||
+			!errors.As(r.Err, &pluginErr) || pluginErr.Details["user"] != "Anton" {
+			t.Fatalf("unexpected error %+v", r.Err)
+		}
+	}
+}
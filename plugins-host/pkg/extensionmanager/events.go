@@ -0,0 +1,149 @@
+package extensionmanager
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEventsBufferSize is the size of the subscription buffer used when the non-positive size is requested.
+const DefaultEventsBufferSize = 64
+
+// EventType is a type of the WSManager event.
+type EventType string
+
+const (
+	// EventPluginRegistered is published when a plugin is registered for the first time.
+	EventPluginRegistered EventType = "pluginRegistered"
+	// EventPluginRestarted is published when a plugin is registered again after it was disconnected.
+	EventPluginRestarted EventType = "pluginRestarted"
+	// EventPluginFailed is published when a plugin can't be started, exits with an error or disconnects unexpectedly.
+	EventPluginFailed EventType = "pluginFailed"
+	// EventProtocolError is published when a message can't be processed or sent.
+	EventProtocolError EventType = "protocolError"
+	// EventOrderingError is published when extensions can't be ordered, e.g. because of circular dependencies.
+	EventOrderingError EventType = "orderingError"
+	// EventExtensionDisabled is published when the extension is disabled because its required extension is missing,
+	// see MissingDependencyDisable.
+	EventExtensionDisabled EventType = "extensionDisabled"
+	// EventExtensionPanic is published when the host or plugin extension implementation panics.
+	// The panic is recovered and returned to the caller as the extension error.
+	EventExtensionPanic EventType = "extensionPanic"
+	// EventBreakerStateChanged is published when the circuit breaker of the plugin extension changes its state.
+	EventBreakerStateChanged EventType = "breakerStateChanged"
+	// EventCardinalityViolated is published when the number of extensions of the declared extension point
+	// is out of its bounds, see ExtensionPointConfig.
+	EventCardinalityViolated EventType = "cardinalityViolated"
+	// EventConflictDetected is published when the registering plugin or its extension conflicts with registered ones.
+	// The conflict is resolved according to the ConflictPolicy when extensions are ordered.
+	EventConflictDetected EventType = "conflictDetected"
+	// EventServerError is published when the WSManager stops accepting plugins' connections unexpectedly.
+	EventServerError EventType = "serverError"
+)
+
+// Event is an event published by the WSManager.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+	// Time is the time when the event happened.
+	Time time.Time
+	// PluginID is the ID of the related plugin, if any.
+	PluginID string
+	// ExtensionPointID is the ID of the related extension point, if any.
+	ExtensionPointID string
+	// ExtensionID is the ID of the related extension, if any.
+	ExtensionID string
+	// BreakerState is the new state of the circuit breaker for EventBreakerStateChanged.
+	BreakerState BreakerState
+	// Err is the error for failure events.
+	Err error
+}
+
+// Subscription receives events published by the WSManager.
+//
+// Publishing never blocks: when the subscription buffer is full, the event is dropped and counted.
+type Subscription struct {
+	m       *WSManager
+	ch      chan Event
+	dropped *atomic.Uint64
+}
+
+// C returns the channel receiving events. It is closed when the subscription is closed.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the subscription buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the events channel.
+func (s *Subscription) Close() {
+	s.m.eventsMu.Lock()
+	defer s.m.eventsMu.Unlock()
+	if _, ok := s.m.subscriptions[s]; !ok {
+		return
+	}
+	delete(s.m.subscriptions, s)
+	close(s.ch)
+}
+
+// Events subscribes to the WSManager events with the buffer of the given size.
+// The subscription should be closed when it is not needed anymore.
+func (m *WSManager) Events(bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventsBufferSize
+	}
+	s := &Subscription{
+		m:       m,
+		ch:      make(chan Event, bufferSize),
+		dropped: &atomic.Uint64{},
+	}
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	m.subscriptions[s] = struct{}{}
+	return s
+}
+
+// DroppedEvents returns the total number of events dropped by all subscriptions.
+func (m *WSManager) DroppedEvents() uint64 {
+	return m.droppedEvents.Load()
+}
+
+// publish sends the event to all subscriptions without blocking.
+func (m *WSManager) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	for s := range m.subscriptions {
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+			m.droppedEvents.Add(1)
+		}
+	}
+}
+
+func (m *WSManager) publishError(eventType EventType, pluginID string, err error) {
+	m.publish(Event{
+		Type:     eventType,
+		PluginID: pluginID,
+		Err:      err,
+	})
+}
+
+// subscribeFailureProcessor runs the failure processor for error events until the subscription is closed.
+func (m *WSManager) subscribeFailureProcessor(p failureProcessor) *Subscription {
+	s := m.Events(DefaultEventsBufferSize)
+	go func() {
+		for e := range s.C() {
+			if e.Err != nil {
+				p(e.Err)
+			}
+		}
+	}()
+	return s
+}
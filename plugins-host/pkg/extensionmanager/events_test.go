@@ -0,0 +1,86 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+)
+
+func TestEventsPluginRegistered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	sub := m.Events(0)
+	defer sub.Close()
+
+	if err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-sub.C():
+		if e.Type != EventPluginRegistered || e.PluginID != "plugin.A" || e.Time.IsZero() {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("pluginRegistered event is not received")
+	}
+}
+
+func TestEventsDoNotBlock(t *testing.T) {
+	m := NewWSManager()
+	sub := m.Events(1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			m.Failure(errors.New("failure"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failure blocks")
+	}
+
+	e := <-sub.C()
+	if e.Type != EventProtocolError || e.Err == nil {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if sub.Dropped() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", sub.Dropped())
+	}
+	if m.DroppedEvents() < 2 {
+		t.Fatalf("expected at least 2 dropped events, got %d", m.DroppedEvents())
+	}
+
+	sub.Close()
+	if _, ok := <-sub.C(); ok {
+		t.Fatal("channel is not closed")
+	}
+	sub.Close()
+}
+
+func TestWithFailureProcessor(t *testing.T) {
+	errs := make(chan error, 1)
+	m := NewWSManager().WithFailureProcessor(func(err error) {
+		errs <- err
+	})
+
+	expected := errors.New("failure")
+	m.Failure(expected)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, expected) {
+			t.Fatalf("expected %v, got %v", expected, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failure processor is not called")
+	}
+}
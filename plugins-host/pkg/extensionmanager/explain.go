@@ -0,0 +1,225 @@
+package extensionmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrderReason is the reason why one extension is executed before another one.
+type OrderReason string
+
+const (
+	// OrderReasonAfter means that the later extension declares the earlier one in AfterExtensionIDs.
+	OrderReasonAfter OrderReason = "after"
+	// OrderReasonBefore means that the earlier extension declares the later one in BeforeExtensionIDs.
+	OrderReasonBefore OrderReason = "before"
+	// OrderReasonRequires means that the later extension declares the earlier one in RequiresExtensionIDs.
+	OrderReasonRequires OrderReason = "requires"
+	// OrderReasonTransitive means that the extensions are ordered via a chain of declared constraints.
+	OrderReasonTransitive OrderReason = "transitive"
+	// OrderReasonPriority means that the earlier extension has the higher Priority.
+	OrderReasonPriority OrderReason = "priority"
+	// OrderReasonTieBreak means that the extensions have the same priority and are ordered
+	// by the plugins load order and then by extension ID.
+	OrderReasonTieBreak OrderReason = "tieBreak"
+	// OrderReasonFallback means that the later extension is the fallback of the earlier one.
+	OrderReasonFallback OrderReason = "fallback"
+)
+
+// OrderEdge explains why the extension From is executed before the extension To.
+type OrderEdge struct {
+	From   string
+	To     string
+	Reason OrderReason
+}
+
+// OrderExplanation is the resolved order of extensions of the extension point with the edges which caused it.
+//
+// Edges contain all declared constraints between registered extensions.
+// Neighbouring extensions which are not ordered by a declared constraint are connected
+// by the transitive, priority or tie-break edge.
+type OrderExplanation struct {
+	ExtensionPointID string
+	// ExtensionIDs are IDs of enabled extensions in the execution order.
+	ExtensionIDs []string
+	Edges        []OrderEdge
+	// Conflicts are conflicts resolved by dropping extensions, see ConflictDropLower.
+	Conflicts []Conflict
+}
+
+// ExplainOrder returns explanations of the resolved extensions order for all extension points
+// in alphabetical order of extension point IDs.
+func (m *WSManager) ExplainOrder() []OrderExplanation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]OrderExplanation, 0, len(m.extensionRuntimeInfoByExtensionPointIDs))
+	for extensionPointID, infos := range m.extensionRuntimeInfoByExtensionPointIDs {
+		explanation := explainOrder(extensionPointID, infos)
+		explanation.Conflicts = m.conflictsByExtensionPointID[extensionPointID]
+		res = append(res, explanation)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ExtensionPointID < res[j].ExtensionPointID
+	})
+	return res
+}
+
+// explainOrder explains the order of already ordered extensions.
+func explainOrder(extensionPointID string, ordered []extensionRuntimeInfo) OrderExplanation {
+	res := OrderExplanation{ExtensionPointID: extensionPointID}
+	_, fallbacksByPrimaryID, aliases := splitFallbacks(ordered, replacementAliases(ordered))
+	infoByID := make(map[string]extensionRuntimeInfo, len(ordered))
+	for _, info := range ordered {
+		res.ExtensionIDs = append(res.ExtensionIDs, info.cfg.ID)
+		infoByID[info.cfg.ID] = info
+	}
+
+	// anchor is the extension which position is taken by the fallback placed after its primary
+	anchors := make(map[string]string, len(ordered))
+	var anchor func(id string) string
+	anchor = func(id string) string {
+		if a, ok := anchors[id]; ok {
+			return a
+		}
+		anchors[id] = id
+		if primaryID := infoByID[id].cfg.FallbackFor; primaryID != "" {
+			if _, ok := fallbacksByPrimaryID[primaryID]; ok {
+				anchors[id] = anchor(primaryID)
+			}
+		}
+		return anchors[id]
+	}
+
+	executedAfter := make(map[string]*Set[string], len(ordered))
+	addEdge := func(from string, to string, reason OrderReason) {
+		if _, ok := infoByID[from]; !ok || from == to {
+			return
+		}
+		if _, ok := infoByID[to]; !ok {
+			return
+		}
+		res.Edges = append(res.Edges, OrderEdge{From: from, To: to, Reason: reason})
+		if executedAfter[to] == nil {
+			executedAfter[to] = NewSet[string]()
+		}
+		executedAfter[to].Add(from)
+	}
+	for _, info := range ordered {
+		for _, id := range resolveAliases(info.cfg.AfterExtensionIDs, aliases) {
+			addEdge(id, info.cfg.ID, OrderReasonAfter)
+		}
+		for _, id := range resolveAliases(info.cfg.RequiresExtensionIDs, aliases) {
+			addEdge(id, info.cfg.ID, OrderReasonRequires)
+		}
+		for _, id := range resolveAliases(info.cfg.BeforeExtensionIDs, aliases) {
+			addEdge(info.cfg.ID, id, OrderReasonBefore)
+		}
+		if _, ok := fallbacksByPrimaryID[info.cfg.FallbackFor]; ok {
+			addEdge(info.cfg.FallbackFor, info.cfg.ID, OrderReasonFallback)
+		}
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		prev, next := ordered[i-1], ordered[i]
+		if executedAfter[next.cfg.ID] != nil && executedAfter[next.cfg.ID].Contains(prev.cfg.ID) {
+			continue
+		}
+		prevAnchor, nextAnchor := infoByID[anchor(prev.cfg.ID)], infoByID[anchor(next.cfg.ID)]
+		switch {
+		case prevAnchor.cfg.ID == nextAnchor.cfg.ID:
+			res.Edges = append(res.Edges, OrderEdge{From: prev.cfg.ID, To: next.cfg.ID, Reason: OrderReasonFallback})
+		case reachable(executedAfter, nextAnchor.cfg.ID, prevAnchor.cfg.ID):
+			res.Edges = append(res.Edges, OrderEdge{From: prev.cfg.ID, To: next.cfg.ID, Reason: OrderReasonTransitive})
+		case prevAnchor.cfg.Priority != nextAnchor.cfg.Priority:
+			res.Edges = append(res.Edges, OrderEdge{From: prev.cfg.ID, To: next.cfg.ID, Reason: OrderReasonPriority})
+		default:
+			res.Edges = append(res.Edges, OrderEdge{From: prev.cfg.ID, To: next.cfg.ID, Reason: OrderReasonTieBreak})
+		}
+	}
+	return res
+}
+
+// reachable reports whether the extension from is executed after the extension to via a chain of edges.
+func reachable(executedAfter map[string]*Set[string], from string, to string) bool {
+	visited := NewSet[string]()
+	stack := []string{from}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited.Contains(id) {
+			continue
+		}
+		visited.Add(id)
+		if executedAfter[id] == nil {
+			continue
+		}
+		for _, dependencyID := range executedAfter[id].Values() {
+			if dependencyID == to {
+				return true
+			}
+			stack = append(stack, dependencyID)
+		}
+	}
+	return false
+}
+
+// conflictSource returns the ID of the winning side of the conflict.
+func conflictSource(c Conflict) string {
+	if c.Winner != "" {
+		return c.Winner
+	}
+	return "plugin " + c.WinnerPluginID
+}
+
+// DOT returns the explanation as the Graphviz DOT digraph.
+// Nodes are numbered in the execution order, edges are labeled with reasons.
+// Dropped conflicting extensions are dashed nodes connected with the winning side by the dashed "conflict" edge.
+func (e OrderExplanation) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", e.ExtensionPointID)
+	b.WriteString("  rankdir=LR;\n")
+	for i, id := range e.ExtensionIDs {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", id, fmt.Sprintf("%d. %s", i+1, id))
+	}
+	for _, edge := range e.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Reason)
+	}
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&b, "  %q [style=dashed];\n", c.Loser)
+		if c.Winner == "" {
+			fmt.Fprintf(&b, "  %q [shape=box];\n", conflictSource(c))
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=\"conflict\", style=dashed];\n", conflictSource(c), c.Loser)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid returns the explanation as the Mermaid flowchart.
+// Nodes are numbered in the execution order, edges are labeled with reasons.
+// Dropped conflicting extensions are connected with the winning side by the dotted "conflict" edge.
+func (e OrderExplanation) Mermaid() string {
+	nodeIDs := make(map[string]string, len(e.ExtensionIDs))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, id := range e.ExtensionIDs {
+		nodeIDs[id] = fmt.Sprintf("e%d", i+1)
+		fmt.Fprintf(&b, "    %s[\"%d. %s\"]\n", nodeIDs[id], i+1, id)
+	}
+	for _, edge := range e.Edges {
+		fmt.Fprintf(&b, "    %s -->|%s| %s\n", nodeIDs[edge.From], edge.Reason, nodeIDs[edge.To])
+	}
+	nodeID := func(id string) string {
+		if _, ok := nodeIDs[id]; !ok {
+			nodeIDs[id] = fmt.Sprintf("c%d", len(nodeIDs)+1)
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", nodeIDs[id], id)
+		}
+		return nodeIDs[id]
+	}
+	for _, c := range e.Conflicts {
+		winner, loser := nodeID(conflictSource(c)), nodeID(c.Loser)
+		fmt.Fprintf(&b, "    %s -.->|conflict| %s\n", winner, loser)
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package extensionmanager
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestExplainOrder(t *testing.T) {
+	infos, err := OrderExtensionRuntimeInfo([]extensionRuntimeInfo{
+		{cfg: pluginstypes.ExtensionConfig{ID: "c", AfterExtensionIDs: []string{"b"}}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "b", AfterExtensionIDs: []string{"a"}}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "a"}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "d", BeforeExtensionIDs: []string{"c"}, Priority: -1}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "e"}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "high", Priority: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := explainOrder("ep", infos)
+	if got := fmt.Sprint(e.ExtensionIDs); got != "[high a b e d c]" {
+		t.Fatalf("unexpected order %s", got)
+	}
+	expected := []OrderEdge{
+		{From: "a", To: "b", Reason: OrderReasonAfter},
+		{From: "d", To: "c", Reason: OrderReasonBefore},
+		{From: "b", To: "c", Reason: OrderReasonAfter},
+		{From: "high", To: "a", Reason: OrderReasonPriority},
+		{From: "b", To: "e", Reason: OrderReasonTieBreak},
+		{From: "e", To: "d", Reason: OrderReasonPriority},
+	}
+	if fmt.Sprint(e.Edges) != fmt.Sprint(expected) {
+		t.Fatalf("expected edges %v, got %v", expected, e.Edges)
+	}
+
+	if dot := e.DOT(); !strings.Contains(dot, `"a" -> "b" [label="after"];`) {
+		t.Fatalf("unexpected DOT:\n%s", dot)
+	}
+	if mermaid := e.Mermaid(); !strings.Contains(mermaid, `e2["2. a"]`) || !strings.Contains(mermaid, "e2 -->|after| e3") {
+		t.Fatalf("unexpected Mermaid:\n%s", mermaid)
+	}
+}
+
+func TestCircularDependencyPath(t *testing.T) {
+	_, err := OrderExtensionRuntimeInfo([]extensionRuntimeInfo{
+		{cfg: pluginstypes.ExtensionConfig{ID: "a", AfterExtensionIDs: []string{"b"}}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "b", AfterExtensionIDs: []string{"c"}}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "c", AfterExtensionIDs: []string{"a"}}},
+		// the extension depending on the cycle is not included in it
+		{cfg: pluginstypes.ExtensionConfig{ID: "0", AfterExtensionIDs: []string{"a"}}},
+	})
+	if err == nil {
+		t.Fatal("expected circular dependency error")
+	}
+	if !strings.HasSuffix(err.Error(), "cycle: a after b after c after a") {
+		t.Fatalf("error doesn't contain the full cycle: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package extensionmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrExtensionDisabled is the reason of extensions disabled via WSManager.DisableExtension.
+var ErrExtensionDisabled = errors.New("extension is disabled by the operator")
+
+// extensionPolicy is the host-side state of extensions switched off by operators.
+type extensionPolicy struct {
+	DisabledExtensions map[string]disabledExtensionPolicy `json:"disabledExtensions"`
+}
+
+type disabledExtensionPolicy struct {
+	Reason     string    `json:"reason,omitempty"`
+	DisabledAt time.Time `json:"disabledAt"`
+}
+
+// LoadExtensionPolicyFile loads IDs of disabled extensions from the JSON file, e.g.:
+//
+//	{
+//	  "disabledExtensions": {
+//	    "plugina.hello.currentDate": {"reason": "prints wrong date", "disabledAt": "2024-01-02T15:04:05Z"}
+//	  }
+//	}
+//
+// The missing file is considered as the empty policy.
+// Further changes made via DisableExtension and EnableExtension are saved to the file.
+func (m *WSManager) LoadExtensionPolicyFile(path string) error {
+	policy := extensionPolicy{DisabledExtensions: make(map[string]disabledExtensionPolicy)}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("read extension policy file: %w", err)
+	default:
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return fmt.Errorf("parse extension policy file %s: %w", path, err)
+		}
+		if policy.DisabledExtensions == nil {
+			policy.DisabledExtensions = make(map[string]disabledExtensionPolicy)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prevPath, prevPolicy := m.extensionPolicyPath, m.extensionPolicy
+	m.extensionPolicyPath = path
+	m.extensionPolicy = policy
+	changedIDs := make([]string, 0, len(prevPolicy.DisabledExtensions)+len(policy.DisabledExtensions))
+	for _, p := range []extensionPolicy{prevPolicy, policy} {
+		for extensionID := range p.DisabledExtensions {
+			changedIDs = append(changedIDs, extensionID)
+		}
+	}
+	return m.applyExtensionPolicy(changedIDs, false, func() {
+		m.extensionPolicyPath, m.extensionPolicy = prevPath, prevPolicy
+	})
+}
+
+// DisableExtension removes extensions with the given ID from execution without uninstalling their plugins.
+// The order of other extensions is kept, so constraints declared through the disabled extension still hold.
+// The state is saved to the policy file if it is loaded via LoadExtensionPolicyFile.
+func (m *WSManager) DisableExtension(extensionID string, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, wasDisabled := m.extensionPolicy.DisabledExtensions[extensionID]
+	m.extensionPolicy.DisabledExtensions[extensionID] = disabledExtensionPolicy{Reason: reason, DisabledAt: time.Now()}
+	return m.applyExtensionPolicy([]string{extensionID}, true, func() {
+		if wasDisabled {
+			m.extensionPolicy.DisabledExtensions[extensionID] = prev
+		} else {
+			delete(m.extensionPolicy.DisabledExtensions, extensionID)
+		}
+	})
+}
+
+// EnableExtension returns extensions with the given ID disabled via DisableExtension back to execution.
+func (m *WSManager) EnableExtension(extensionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.extensionPolicy.DisabledExtensions[extensionID]; !ok {
+		return nil
+	}
+	prev := m.extensionPolicy.DisabledExtensions[extensionID]
+	delete(m.extensionPolicy.DisabledExtensions, extensionID)
+	return m.applyExtensionPolicy([]string{extensionID}, true, func() {
+		m.extensionPolicy.DisabledExtensions[extensionID] = prev
+	})
+}
+
+// applyExtensionPolicy reorders extension points containing the changed extensions and saves the policy if save is true.
+// If extension points can't be reordered or the policy can't be saved, the change is rolled back,
+// so the policy file always matches the applied state.
+// m.mu should be locked.
+func (m *WSManager) applyExtensionPolicy(changedIDs []string, save bool, rollback func()) error {
+	m.touchExtensionPoints(changedIDs)
+	err := m.reorderIfOrdered()
+	if err == nil && save {
+		err = m.saveExtensionPolicy()
+	}
+	if err != nil {
+		rollback()
+		// the previous state was ordered successfully, errors are published as events
+		m.touchExtensionPoints(changedIDs)
+		_ = m.reorderIfOrdered()
+	}
+	return err
+}
+
+// DisabledExtensionIDs returns IDs of extensions disabled via DisableExtension in alphabetical order.
+func (m *WSManager) DisabledExtensionIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.extensionPolicy.DisabledExtensions))
+	for id := range m.extensionPolicy.DisabledExtensions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// splitOperatorDisabled removes extensions disabled via DisableExtension from already ordered extensions,
+// so the relative order of the rest ones is kept.
+// m.mu should be locked.
+func (m *WSManager) splitOperatorDisabled(ordered []extensionRuntimeInfo) ([]extensionRuntimeInfo, []disabledExtension) {
+	if len(m.extensionPolicy.DisabledExtensions) == 0 {
+		return ordered, nil
+	}
+	var (
+		enabled  []extensionRuntimeInfo
+		disabled []disabledExtension
+	)
+	for _, info := range ordered {
+		policy, ok := m.extensionPolicy.DisabledExtensions[info.cfg.ID]
+		if !ok {
+			enabled = append(enabled, info)
+			continue
+		}
+		err := fmt.Errorf(`extension "%s": %w`, info.cfg.ID, ErrExtensionDisabled)
+		if policy.Reason != "" {
+			err = fmt.Errorf(`extension "%s": %w: %s`, info.cfg.ID, ErrExtensionDisabled, policy.Reason)
+		}
+		disabled = append(disabled, disabledExtension{info: info, err: err})
+	}
+	return enabled, disabled
+}
+
+// saveExtensionPolicy writes the policy to the temporary file and renames it, so the file is never partially written.
+// m.mu should be locked.
+func (m *WSManager) saveExtensionPolicy() error {
+	if m.extensionPolicyPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m.extensionPolicy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal extension policy: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.extensionPolicyPath), filepath.Base(m.extensionPolicyPath)+".*")
+	if err != nil {
+		return fmt.Errorf("save extension policy: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("save extension policy: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save extension policy: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.extensionPolicyPath); err != nil {
+		return fmt.Errorf("save extension policy: %w", err)
+	}
+	return nil
+}
+
+// touchExtensionPoints marks extension points containing extensions with the given IDs as changed.
+// m.mu should be locked.
+func (m *WSManager) touchExtensionPoints(extensionIDs []string) {
+	if len(extensionIDs) == 0 {
+		return
+	}
+	ids := NewSet[string]()
+	for _, extensionID := range extensionIDs {
+		ids.Add(extensionID)
+	}
+	for extensionPointID, infos := range m.extensionRuntimeInfoByExtensionPointIDs {
+		for _, info := range infos {
+			if ids.Contains(info.cfg.ID) {
+				m.unorderedExtensionPointIDs.Add(extensionPointID)
+				break
+			}
+		}
+	}
+	for extensionPointID, disabled := range m.disabledExtensionsByExtensionPointID {
+		for _, d := range disabled {
+			if ids.Contains(d.info.cfg.ID) {
+				m.unorderedExtensionPointIDs.Add(extensionPointID)
+				break
+			}
+		}
+	}
+}
+
+// reorderIfOrdered applies changes immediately if plugins are already loaded, otherwise they are applied by LoadPlugins.
+// m.mu should be locked.
+func (m *WSManager) reorderIfOrdered() error {
+	if !m.pluginsOrdered {
+		return nil
+	}
+	return m.reorderChangedExtensionPoints()
+}
@@ -0,0 +1,151 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func newPolicyTestManager(ctx context.Context, t *testing.T, policyPath string) *WSManager {
+	t.Helper()
+	m := NewWSManager()
+	if err := m.LoadExtensionPolicyFile(policyPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []pluginstypes.ExtensionConfig{
+		{ID: "b", ExtensionPointID: "chain", AfterExtensionIDs: []string{"mid"}, Priority: 10},
+		{ID: "mid", ExtensionPointID: "chain", AfterExtensionIDs: []string{"a"}},
+		{ID: "a", ExtensionPointID: "chain"},
+	} {
+		id := cfg.ID
+		Extension[string, string](m, cfg, func(ctx context.Context, in string) (string, error) {
+			return id, nil
+		})
+	}
+	if err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func executedChain(ctx context.Context, t *testing.T, m *WSManager) string {
+	t.Helper()
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "chain", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	return fmt.Sprint(outs)
+}
+
+func TestDisableExtension(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+
+	m := newPolicyTestManager(ctx, t, policyPath)
+	if err := m.DisableExtension("mid", "misbehaves"); err != nil {
+		t.Fatal(err)
+	}
+	if got := executedChain(ctx, t, m); got != "[a b]" {
+		t.Fatalf("constraints through the disabled extension should hold, got %s", got)
+	}
+	disabled := m.Introspect().ExtensionPoints[0].Disabled
+	if len(disabled) != 1 || disabled[0].Config.ID != "mid" || !strings.Contains(disabled[0].DisabledReason, "misbehaves") {
+		t.Fatalf("unexpected disabled extensions %+v", disabled)
+	}
+
+	// the state is restored from the policy file
+	m = newPolicyTestManager(ctx, t, policyPath)
+	if got := fmt.Sprint(m.DisabledExtensionIDs()); got != "[mid]" {
+		t.Fatalf("disabled extensions are not loaded from the policy file, got %s", got)
+	}
+	if got := executedChain(ctx, t, m); got != "[a b]" {
+		t.Fatalf("disabled extension is executed, got %s", got)
+	}
+	if err := m.EnableExtension("mid"); err != nil {
+		t.Fatal(err)
+	}
+	if got := executedChain(ctx, t, m); got != "[a mid b]" {
+		t.Fatalf("enabled extension is not executed, got %s", got)
+	}
+
+	m = newPolicyTestManager(ctx, t, policyPath)
+	if len(m.DisabledExtensionIDs()) != 0 {
+		t.Fatal("enabled extension is not saved to the policy file")
+	}
+}
+
+func TestDisableExtensionRollback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	m := NewWSManager().WithExtensionPoint(ExtensionPointConfig{ID: "storage", DefaultExtensionID: "app.storage"})
+	if err := m.LoadExtensionPolicyFile(policyPath); err != nil {
+		t.Fatal(err)
+	}
+	// the default extension can't be ordered together with the plugin's one
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:                "app.storage",
+		ExtensionPointID:  "storage",
+		AfterExtensionIDs: []string{"a.storage"},
+	}, func(ctx context.Context, in string) (string, error) {
+		return "app.storage", nil
+	})
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:                "a.storage",
+			ExtensionPointID:  "storage",
+			AfterExtensionIDs: []string{"app.storage"},
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "a.storage", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Extension[string, string](m, pluginstypes.ExtensionConfig{ID: "app.other", ExtensionPointID: "other"},
+		func(ctx context.Context, in string) (string, error) {
+			return "app.other", nil
+		})
+
+	m.mu.Lock()
+	m.touchExtensionPoints([]string{"a.storage"})
+	touched := fmt.Sprint(m.unorderedExtensionPointIDs.Values())
+	_ = m.reorderChangedExtensionPoints()
+	m.mu.Unlock()
+	if touched != "[storage]" {
+		t.Fatalf("only extension points containing the extension should be reordered, got %s", touched)
+	}
+
+	if err := m.DisableExtension("a.storage", ""); err == nil {
+		t.Fatal("expected the ordering error")
+	}
+	if ids := m.DisabledExtensionIDs(); len(ids) != 0 {
+		t.Fatalf("disabling should be rolled back, got %v", ids)
+	}
+	if _, err := os.Stat(policyPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("policy file should not be saved, got %v", err)
+	}
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "storage", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	if fmt.Sprint(outs) != "[a.storage]" {
+		t.Fatalf("previous state should be kept, got %v", outs)
+	}
+}
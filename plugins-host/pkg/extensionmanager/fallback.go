@@ -0,0 +1,78 @@
+package extensionmanager
+
+import (
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// fallbackTracker decides which fallback extensions are executed during the extension point execution.
+//
+// Fallbacks follow their primary extension in the execution order. The first fallback is executed if the primary
+// extension fails, each next one only if the previous fallback fails or doesn't run too.
+type fallbackTracker struct {
+	// primaryIDByFallbackID is the ID of the registered primary extension of the fallback.
+	primaryIDByFallbackID map[string]string
+	// pendingFallbacks is the number of not visited fallbacks of the extension.
+	pendingFallbacks map[string]int
+	// failureByExtensionID are failures of extensions waiting for their fallbacks.
+	failureByExtensionID map[string]extensionFailure
+}
+
+// extensionFailure is the result of the failed extension, it is returned if none of its fallbacks runs.
+type extensionFailure struct {
+	err  error
+	meta pluginstypes.ResultMeta
+}
+
+func newFallbackTracker(infos []extensionRuntimeInfo) *fallbackTracker {
+	t := &fallbackTracker{
+		primaryIDByFallbackID: make(map[string]string),
+		pendingFallbacks:      make(map[string]int),
+		failureByExtensionID:  make(map[string]extensionFailure),
+	}
+	extensionIDs := NewSet[string]()
+	for _, info := range infos {
+		extensionIDs.Add(info.cfg.ID)
+	}
+	replacerIDs := replacementAliases(infos)
+	for _, info := range infos {
+		if primaryID := resolveAlias(info.cfg.FallbackFor, replacerIDs); primaryID != "" && extensionIDs.Contains(primaryID) {
+			t.primaryIDByFallbackID[info.cfg.ID] = primaryID
+			t.pendingFallbacks[primaryID]++
+		}
+	}
+	return t
+}
+
+// visit is called before the execution of the extension. It reports whether the extension should be executed
+// and returns the failure of the primary extension for fallbacks.
+func (t *fallbackTracker) visit(extensionID string) (execute bool, primaryFailure *extensionFailure) {
+	primaryID, ok := t.primaryIDByFallbackID[extensionID]
+	if !ok {
+		return true, nil
+	}
+	t.pendingFallbacks[primaryID]--
+	failure, failed := t.failureByExtensionID[primaryID]
+	if !failed {
+		return false, nil
+	}
+	return true, &failure
+}
+
+// failed records the failed extension. It reports whether one of the following fallbacks replaces it,
+// i.e. the fallback of the extension or the next fallback of the same primary extension.
+func (t *fallbackTracker) failed(extensionID string, failure extensionFailure) bool {
+	for id, ok := extensionID, true; ok; id, ok = t.primaryIDByFallbackID[id] {
+		if t.pendingFallbacks[id] > 0 {
+			t.failureByExtensionID[id] = failure
+			return true
+		}
+	}
+	return false
+}
+
+// succeeded marks failures replaced by the fallback extension as handled, so other fallbacks are not executed.
+func (t *fallbackTracker) succeeded(extensionID string) {
+	for id, ok := t.primaryIDByFallbackID[extensionID]; ok; id, ok = t.primaryIDByFallbackID[id] {
+		delete(t.failureByExtensionID, id)
+	}
+}
@@ -0,0 +1,223 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func orderedIDs(t *testing.T, cfgs ...pluginstypes.ExtensionConfig) []string {
+	t.Helper()
+	var infos []extensionRuntimeInfo
+	for _, cfg := range cfgs {
+		infos = append(infos, extensionRuntimeInfo{cfg: cfg})
+	}
+	ordered, err := OrderExtensionRuntimeInfo(infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, info := range ordered {
+		ids = append(ids, info.cfg.ID)
+	}
+	return ids
+}
+
+func TestOrderFallbacks(t *testing.T) {
+	a := pluginstypes.ExtensionConfig{ID: "a"}
+	primary := pluginstypes.ExtensionConfig{ID: "primary", AfterExtensionIDs: []string{"a"}}
+	b := pluginstypes.ExtensionConfig{ID: "b", AfterExtensionIDs: []string{"primary"}}
+	fallback := pluginstypes.ExtensionConfig{ID: "fallback", FallbackFor: "primary", BeforeExtensionIDs: []string{"a"}}
+
+	if got := fmt.Sprint(orderedIDs(t, b, fallback, primary, a)); got != "[a primary fallback b]" {
+		t.Fatalf("fallback should take the primary's position, got %s", got)
+	}
+	if got := fmt.Sprint(orderedIDs(t, b, fallback, a)); got != "[fallback a b]" {
+		t.Fatalf("fallback should be ordered instead of the missing primary, got %s", got)
+	}
+
+	_, err := OrderExtensionRuntimeInfo([]extensionRuntimeInfo{
+		{cfg: pluginstypes.ExtensionConfig{ID: "x", FallbackFor: "y"}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "y", FallbackFor: "x"}},
+	})
+	if err == nil {
+		t.Fatal("expected circular fallback error")
+	}
+}
+
+func TestExecuteFallbacks(t *testing.T) {
+	ctx := context.Background()
+	m := NewWSManager()
+	primaryErr := errors.New("primary is broken")
+	failPrimary := true
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.primary",
+		ExtensionPointID: "number",
+	}, func(ctx context.Context, in string) (string, error) {
+		if failPrimary {
+			return "", primaryErr
+		}
+		return "primary", nil
+	})
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.fallback",
+		ExtensionPointID: "number",
+		FallbackFor:      "app.primary",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "fallback", nil
+	})
+	if err := m.LoadPlugins(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []pluginstypes.ExecuteExtensionResult[string]
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Err != nil || got[0].Out != "fallback" {
+		t.Fatalf("expected the fallback result, got %+v", got)
+	}
+	if meta := got[0].Meta; meta.FallbackFor != "app.primary" || len(meta.Attempts) != 2 ||
+		meta.Attempts[0].ExtensionID != "app.primary" || meta.Attempts[0].Error == nil {
+		t.Fatalf("expected the failed primary attempt in the metadata, got %+v", meta)
+	}
+
+	failPrimary = false
+	got = nil
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Out != "primary" {
+		t.Fatalf("fallback should not be executed when the primary succeeds, got %+v", got)
+	}
+}
+
+func TestExecuteSeveralFallbacks(t *testing.T) {
+	ctx := context.Background()
+	m := NewWSManager()
+	failFirstFallback := false
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.primary",
+		ExtensionPointID: "number",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "", errors.New("primary is broken")
+	})
+	for _, id := range []string{"app.fallback1", "app.fallback2"} {
+		id := id
+		Extension[string, string](m, pluginstypes.ExtensionConfig{
+			ID:               id,
+			ExtensionPointID: "number",
+			FallbackFor:      "app.primary",
+		}, func(ctx context.Context, in string) (string, error) {
+			if id == "app.fallback1" && failFirstFallback {
+				return "", errors.New("first fallback is broken")
+			}
+			return id, nil
+		})
+	}
+	if err := m.LoadPlugins(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []pluginstypes.ExecuteExtensionResult[string]
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Err != nil || got[0].Out != "app.fallback1" {
+		t.Fatalf("only the first fallback should replace the primary, got %+v", got)
+	}
+
+	failFirstFallback = true
+	got = nil
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Err != nil || got[0].Out != "app.fallback2" || len(got[0].Meta.Attempts) != 3 {
+		t.Fatalf("the next fallback should be executed after the failed one, got %+v", got)
+	}
+}
+
+func TestExecuteFallbackSkippedByCondition(t *testing.T) {
+	ctx := context.Background()
+	m := NewWSManager()
+	primaryErr := errors.New("primary is broken")
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.primary",
+		ExtensionPointID: "number",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "", primaryErr
+	})
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.fallback",
+		ExtensionPointID: "number",
+		FallbackFor:      "app.primary",
+		Condition:        "$=fallback",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "fallback", nil
+	})
+	if err := m.LoadPlugins(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []pluginstypes.ExecuteExtensionResult[string]
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "primary") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || !errors.Is(got[0].Err, primaryErr) || got[0].Meta.ExtensionID != "app.primary" {
+		t.Fatalf("the primary's error should be returned when its fallback doesn't run, got %+v", got)
+	}
+
+	got = nil
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "fallback") {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Err != nil || got[0].Out != "fallback" {
+		t.Fatalf("expected the fallback result, got %+v", got)
+	}
+}
+
+func TestExecuteFallbackOnTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	release := make(chan struct{})
+	defer close(release)
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "a.primary",
+			ExtensionPointID: "number",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			// the plugin doesn't answer until the test ends
+			<-release
+			return "primary", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.fallback",
+		ExtensionPointID: "number",
+		FallbackFor:      "a.primary",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "fallback", nil
+	})
+
+	var got []pluginstypes.ExecuteExtensionResult[string]
+	for r := range ExecuteExtensions[string, string](ctx, m, "number", "", pluginstypes.WithTimeout(50*time.Millisecond)) {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Err != nil || got[0].Out != "fallback" {
+		t.Fatalf("expected the fallback result, got %+v", got)
+	}
+	if attempts := got[0].Meta.Attempts; len(attempts) != 2 ||
+		pluginstypes.CodeOf(attempts[0].Error) != pluginstypes.CodeDeadlineExceeded {
+		t.Fatalf("expected the timed out primary attempt, got %+v", attempts)
+	}
+}
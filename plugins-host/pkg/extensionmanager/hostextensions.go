@@ -3,6 +3,8 @@ package extensionmanager
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
+
 	types "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
 )
 
@@ -42,6 +44,9 @@ func Extension[IN any, OUT any](m *WSManager, cfg types.ExtensionConfig, impleme
 			if !jsonInput {
 				return o, e
 			}
+			if e != nil {
+				return json.RawMessage(nil), e
+			}
 
 			var rawJson json.RawMessage
 			rawJson, err := json.Marshal(o)
@@ -49,16 +54,15 @@ func Extension[IN any, OUT any](m *WSManager, cfg types.ExtensionConfig, impleme
 		},
 	})
 
+	m.extensionRuntimeInfoByExtensionPointIDs[cfg.ExtensionPointID] = currentExtensionRuntimeInfos
+	m.unorderedExtensionPointIDs.Add(cfg.ExtensionPointID)
 	if m.pluginsOrdered {
-		var err error
-		currentExtensionRuntimeInfos, err = OrderExtensionRuntimeInfo(currentExtensionRuntimeInfos)
-		if err != nil {
-			m.mu.Unlock()
-			m.Failure(err)
-
-			// lock to unlock after in defer
-			m.mu.Lock()
+		if err := m.reorderExtensionPoint(cfg.ExtensionPointID); err != nil {
+			m.logger.Error(
+				"order extensions of the host extension",
+				slog.String("extensionID", cfg.ID),
+				slog.String("err", err.Error()),
+			)
 		}
 	}
-	m.extensionRuntimeInfoByExtensionPointIDs[cfg.ExtensionPointID] = currentExtensionRuntimeInfos
 }
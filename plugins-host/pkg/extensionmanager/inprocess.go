@@ -0,0 +1,52 @@
+package extensionmanager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-host/pkg/random"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/memory"
+)
+
+// LoadInProcess loads the plugin linked into the host binary.
+//
+// The register function receives a new plugin instance and should register the plugin's extensions
+// the same way as the plugin's main function does. The plugin is run in the host process without
+// any child process or socket, and is connected to the WSManager via the in-memory pipe,
+// so the same registration, ordering and protocol code paths are used as for out-of-process plugins.
+//
+// The function waits until the plugin is registered. If the context is canceled, it returns an error.
+func (m *WSManager) LoadInProcess(ctx context.Context, pluginID string, register func(p *plugins.Plugin)) error {
+	hostConn, pluginConn := memory.Pipe()
+	secret := random.GenerateRandomString(64)
+	registered := make(chan string, 1)
+	failed := make(chan error, 1)
+	m.mu.Lock()
+	m.registrationWaiterBySecret[secret] = registered
+	m.assignLoadIndex(secret)
+	m.mu.Unlock()
+	p := plugins.New(
+		pluginID,
+		plugins.WithSecret(secret),
+		plugins.WithDialer(memory.NewDialer(pluginConn)),
+	)
+	register(p)
+
+	go m.handle(hostConn)
+	go func() {
+		if err := p.Run(context.WithoutCancel(ctx)); err != nil {
+			m.logger.Error(
+				"in-process plugin stopped",
+				slog.String("pluginID", pluginID),
+				slog.String("err", err.Error()),
+			)
+			err = fmt.Errorf("in-process plugin %s stopped: %w", pluginID, err)
+			m.publishError(EventPluginFailed, pluginID, err)
+			failed <- err
+		}
+	}()
+
+	return m.awaitPlugins(ctx, map[string]struct{}{secret: {}}, registered, failed)
+}
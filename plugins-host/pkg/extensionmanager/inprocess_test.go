@@ -0,0 +1,74 @@
+package extensionmanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/memory"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// connectPlugin connects the in-process plugin which is not awaited by the WSManager, e.g. the reconnected one.
+func connectPlugin(ctx context.Context, m *WSManager, pluginID string, register func(p *plugins.Plugin)) {
+	hostConn, pluginConn := memory.Pipe()
+	p := plugins.New(pluginID, plugins.WithSecret(pluginID+".reconnected"), plugins.WithDialer(memory.NewDialer(pluginConn)))
+	register(p)
+	go m.handle(hostConn)
+	go func() {
+		_ = p.Run(ctx)
+	}()
+}
+
+func TestLoadInProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.name",
+		ExtensionPointID: "name",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "host", nil
+	})
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:                "app.hello",
+		ExtensionPointID:  "hello",
+		AfterExtensionIDs: []string{"plugina.hello"},
+	}, func(ctx context.Context, in string) (string, error) {
+		return "host says hello to " + in, nil
+	})
+
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.hello",
+			ExtensionPointID: "hello",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			var name string
+			for r := range plugins.ExecuteExtensionsOf[string, string](ctx, p, "name", "") {
+				if r.Err != nil {
+					return "", r.Err
+				}
+				name = r.Out
+			}
+			return fmt.Sprintf("plugin A says hello to %s via %s", in, name), nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "hello", "Anton") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		got = append(got, r.Out)
+	}
+	expected := []string{"plugin A says hello to Anton via host", "host says hello to Anton"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
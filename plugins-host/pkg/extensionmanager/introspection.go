@@ -0,0 +1,90 @@
+package extensionmanager
+
+import (
+	"sort"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// Introspection is a snapshot of the WSManager state, see WSManager.Introspect.
+type Introspection struct {
+	// Plugins are IDs of the registered plugins in alphabetical order.
+	Plugins []string
+	// ExtensionPoints are extension points with registered extensions in alphabetical order.
+	ExtensionPoints []ExtensionPointInfo
+}
+
+// ExtensionPointInfo describes the extension point.
+type ExtensionPointInfo struct {
+	// ID is the ID of the extension point.
+	ID string
+	// Extensions are extensions of the extension point in the execution order.
+	Extensions []ExtensionInfo
+	// Disabled are registered extensions which are not executed, e.g. disabled via WSManager.DisableExtension,
+	// replaced, dropped because of conflicts or missing required extensions.
+	Disabled []ExtensionInfo
+}
+
+// ExtensionInfo describes the registered extension.
+type ExtensionInfo struct {
+	// Config is the configuration of the extension.
+	Config pluginstypes.ExtensionConfig
+	// PluginID is the ID of the plugin implementing the extension, it is empty for host extensions.
+	PluginID string
+	// Breaker is the state of the circuit breaker of the plugin extension,
+	// it is nil if there were no executions with breakers enabled.
+	Breaker *BreakerInfo
+	// DisabledReason describes why the extension is not executed, it is empty for enabled extensions.
+	DisabledReason string
+}
+
+// Introspect returns the snapshot of the registered plugins and extensions.
+func (m *WSManager) Introspect() Introspection {
+	m.mu.Lock()
+	var res Introspection
+	for pluginID := range m.channelByPluginID {
+		res.Plugins = append(res.Plugins, pluginID)
+	}
+	extensionPointIDs := NewSet[string]()
+	for extensionPointID := range m.extensionRuntimeInfoByExtensionPointIDs {
+		extensionPointIDs.Add(extensionPointID)
+	}
+	for extensionPointID := range m.disabledExtensionsByExtensionPointID {
+		extensionPointIDs.Add(extensionPointID)
+	}
+	for _, extensionPointID := range extensionPointIDs.Values() {
+		extensionPoint := ExtensionPointInfo{ID: extensionPointID}
+		for _, runtimeInfo := range m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID] {
+			extensionPoint.Extensions = append(extensionPoint.Extensions, ExtensionInfo{
+				Config:   runtimeInfo.cfg,
+				PluginID: runtimeInfo.pluginID,
+			})
+		}
+		for _, d := range m.disabledExtensionsByExtensionPointID[extensionPointID] {
+			extensionPoint.Disabled = append(extensionPoint.Disabled, ExtensionInfo{
+				Config:         d.info.cfg,
+				PluginID:       d.info.pluginID,
+				DisabledReason: d.err.Error(),
+			})
+		}
+		sort.Slice(extensionPoint.Disabled, func(i, j int) bool {
+			return extensionPoint.Disabled[i].Config.ID < extensionPoint.Disabled[j].Config.ID
+		})
+		res.ExtensionPoints = append(res.ExtensionPoints, extensionPoint)
+	}
+	m.mu.Unlock()
+
+	sort.Strings(res.Plugins)
+	sort.Slice(res.ExtensionPoints, func(i, j int) bool {
+		return res.ExtensionPoints[i].ID < res.ExtensionPoints[j].ID
+	})
+	for _, extensionPoint := range res.ExtensionPoints {
+		for i := range extensionPoint.Extensions {
+			extension := &extensionPoint.Extensions[i]
+			if extension.PluginID != "" {
+				extension.Breaker = m.breakerInfo(extension.PluginID, extension.Config.ID)
+			}
+		}
+	}
+	return res
+}
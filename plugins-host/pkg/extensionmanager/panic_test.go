@@ -0,0 +1,75 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestExtensionPanicIsolation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	sub := m.Events(0)
+	defer sub.Close()
+
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.panic",
+		ExtensionPointID: "host.panic",
+	}, func(ctx context.Context, in string) (string, error) {
+		panic("host is broken")
+	})
+
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.panic",
+			ExtensionPointID: "plugin.panic",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			panic("plugin is broken")
+		}))
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.hello",
+			ExtensionPointID: "hello",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "hello " + in, nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sub.C() // pluginRegistered
+
+	for _, extensionPointID := range []string{"host.panic", "plugin.panic"} {
+		for r := range ExecuteExtensions[string, string](ctx, m, extensionPointID, "") {
+			if !pluginstypes.IsPanic(r.Err) {
+				t.Fatalf("%s: expected panic error, got %v", extensionPointID, r.Err)
+			}
+		}
+		select {
+		case e := <-sub.C():
+			if e.Type != EventExtensionPanic || e.ExtensionPointID != extensionPointID {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		case <-ctx.Done():
+			t.Fatalf("%s: extensionPanic event is not received", extensionPointID)
+		}
+	}
+
+	var pluginErr *pluginstypes.PluginError
+	for r := range ExecuteExtensions[string, string](ctx, m, "plugin.panic", "") {
+		if !errors.As(r.Err, &pluginErr) || pluginErr.Stack == "" {
+			t.Fatalf("expected plugin error with stack, got %v", r.Err)
+		}
+	}
+
+	for r := range ExecuteExtensions[string, string](ctx, m, "hello", "Anton") {
+		if r.Err != nil || r.Out != "hello Anton" {
+			t.Fatalf("plugin doesn't serve extensions after panic: %v, %v", r.Out, r.Err)
+		}
+	}
+}
@@ -1,184 +1,331 @@
 package extensionmanager
 
 import (
+	"container/heap"
 	"fmt"
-	"slices"
+	"sort"
+	"strings"
 )
 
-type extensionRuntimeInfoWithDependenciesInfo struct {
+// orderNode is the extension with its dependants used by the topological sort.
+type orderNode struct {
 	info                    extensionRuntimeInfo
-	dependenciesIDs         *Set[string]
-	unsatisfiedDependencies *Set[string]
-	dependants              *Set[*extensionRuntimeInfoWithDependenciesInfo]
-	processed               bool
+	dependants              []*orderNode
+	unsatisfiedDependencies int
 }
 
-func newExtensionRuntimeInfoWithDependenciesInfo(
-	info extensionRuntimeInfo,
-) *extensionRuntimeInfoWithDependenciesInfo {
-	return &extensionRuntimeInfoWithDependenciesInfo{
-		info:                    info,
-		dependenciesIDs:         NewSet[string](),
-		unsatisfiedDependencies: NewSet[string](),
-		dependants:              NewSet[*extensionRuntimeInfoWithDependenciesInfo](),
-		processed:               false,
-	}
+// readyNodes is the priority queue of extensions which dependencies are satisfied, ordered according to precedes.
+type readyNodes []*orderNode
+
+func (r readyNodes) Len() int           { return len(r) }
+func (r readyNodes) Less(i, j int) bool { return precedes(r[i].info, r[j].info) }
+func (r readyNodes) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func (r *readyNodes) Push(x any) {
+	*r = append(*r, x.(*orderNode))
+}
+
+func (r *readyNodes) Pop() any {
+	old := *r
+	node := old[len(old)-1]
+	old[len(old)-1] = nil
+	*r = old[:len(old)-1]
+	return node
 }
 
 // OrderExtensionRuntimeInfo orders a list of extension runtime information objects based on their dependencies.
 //
 // The function takes in a list of extension runtime information objects and returns a list of the same objects,
 // but with the dependencies resolved and the objects ordered based on their dependencies.
+//
+// Fallback extensions are placed right after their primary extensions, so they take the primary's position.
+// If the primary extension is not registered, the fallback is ordered instead of it,
+// i.e. constraints referencing the primary extension ID are applied to the fallback.
+//
+// Extensions replaced by other ones via ExtensionConfig.Replaces are removed. The replacing extension takes
+// the replaced extension's place: it gets the replaced extension's constraints, and constraints and fallbacks
+// referencing the replaced extension ID are applied to it.
 func OrderExtensionRuntimeInfo(orig []extensionRuntimeInfo) ([]extensionRuntimeInfo, error) {
-	recursiveDependenciesByName, err := createRecursiveDependenciesByExtensionID(orig)
+	kept, replaced, replacerIDs, err := splitReplaced(orig)
 	if err != nil {
 		return nil, err
 	}
-
-	return prioritizeExtensionRuntimeInfos(orig, recursiveDependenciesByName)
+	return orderReplaced(kept, replaced, replacerIDs)
 }
 
-func prioritizeExtensionRuntimeInfos(orig []extensionRuntimeInfo, recursiveDependenciesByName map[string]*Set[string]) ([]extensionRuntimeInfo, error) {
-	extensionRuntimeInfoByExtensionID := make(map[string]*extensionRuntimeInfoWithDependenciesInfo)
-	extensionRuntimeInfoWithDependenciesInfos := make([]*extensionRuntimeInfoWithDependenciesInfo, 0, len(orig))
-	for _, info := range orig {
-		if _, ok := extensionRuntimeInfoByExtensionID[info.cfg.ID]; ok {
-			return nil, fmt.Errorf(`extensionID duplication found for id "%s"`, info.cfg.ID)
-		}
-		extensionRuntimeInfoWithDependenciesInfo := newExtensionRuntimeInfoWithDependenciesInfo(info)
-		extensionRuntimeInfoByExtensionID[info.cfg.ID] = extensionRuntimeInfoWithDependenciesInfo
-		extensionRuntimeInfoWithDependenciesInfos = append(
-			extensionRuntimeInfoWithDependenciesInfos,
-			extensionRuntimeInfoWithDependenciesInfo,
-		)
+// orderReplaced orders extensions kept after replacements applying constraints of the replaced extensions,
+// see splitReplaced.
+func orderReplaced(
+	kept []extensionRuntimeInfo,
+	replaced []extensionRuntimeInfo,
+	replacerIDs map[string]string,
+) ([]extensionRuntimeInfo, error) {
+	primaries, fallbacksByPrimaryID, aliases := splitFallbacks(kept, replacerIDs)
+	dependenciesByExtensionID, err := createDependenciesByExtensionID(primaries, replaced, aliases)
+	if err != nil {
+		return nil, err
 	}
+	sortedInfos, err := sortTopologically(primaries, dependenciesByExtensionID)
+	if err != nil {
+		return nil, err
+	}
+	return insertFallbacks(sortedInfos, fallbacksByPrimaryID, len(kept))
+}
 
-	// there could be sort by custom specified priority
+// precedes reports whether the extension a should be executed before b when there are no constraints between them:
+// extensions with higher priority go first, then extensions of earlier loaded plugins, then ordered by extension ID.
+func precedes(a extensionRuntimeInfo, b extensionRuntimeInfo) bool {
+	if a.cfg.Priority != b.cfg.Priority {
+		return a.cfg.Priority > b.cfg.Priority
+	}
+	if a.loadIndex != b.loadIndex {
+		return a.loadIndex < b.loadIndex
+	}
+	return a.cfg.ID < b.cfg.ID
+}
 
-	// process dependency declared priority
-	for _, info := range extensionRuntimeInfoWithDependenciesInfos {
-		if deps, ok := recursiveDependenciesByName[info.info.cfg.ID]; ok {
-			for _, depExtensionID := range deps.Values() {
-				if dep, ok := extensionRuntimeInfoByExtensionID[depExtensionID]; ok {
-					dep.dependants.Add(info)
-					info.dependenciesIDs.Add(dep.info.cfg.ID)
-				}
+// splitFallbacks separates fallback extensions whose primary extensions are registered.
+// Other fallback extensions replace their missing primary extensions in ordering constraints via aliases.
+// Fallbacks of replaced extensions are considered as fallbacks of the replacing ones, see replacerIDs of splitReplaced.
+func splitFallbacks(orig []extensionRuntimeInfo, replacerIDs map[string]string) (
+	primaries []extensionRuntimeInfo,
+	fallbacksByPrimaryID map[string][]extensionRuntimeInfo,
+	aliases map[string]string,
+) {
+	extensionIDs := NewSet[string]()
+	for _, info := range orig {
+		extensionIDs.Add(info.cfg.ID)
+	}
+	fallbacksByPrimaryID = make(map[string][]extensionRuntimeInfo)
+	aliases = make(map[string]string, len(replacerIDs))
+	for replacedID, replacerID := range replacerIDs {
+		aliases[replacedID] = replacerID
+	}
+	aliasInfos := make(map[string]extensionRuntimeInfo)
+	for _, info := range orig {
+		primaryID := resolveAlias(info.cfg.FallbackFor, replacerIDs)
+		switch {
+		case primaryID == "":
+			primaries = append(primaries, info)
+		case extensionIDs.Contains(primaryID):
+			fallbacksByPrimaryID[primaryID] = append(fallbacksByPrimaryID[primaryID], info)
+		default:
+			primaries = append(primaries, info)
+			if current, ok := aliasInfos[primaryID]; !ok || precedes(info, current) {
+				aliasInfos[primaryID] = info
 			}
 		}
-		// init unsatisfied dependencies
-		info.unsatisfiedDependencies.AddAll(info.dependenciesIDs)
 	}
-
-	// calculate real dependencies
-	var sortedInfos []extensionRuntimeInfo
-	var err error
-	sortedInfos, err = addWithOrderPrevention(extensionRuntimeInfoWithDependenciesInfos, sortedInfos)
-	if err != nil {
-		return nil, err
+	for primaryID, info := range aliasInfos {
+		aliases[primaryID] = info.cfg.ID
 	}
-	return sortedInfos, nil
+	for _, fallbacks := range fallbacksByPrimaryID {
+		sort.SliceStable(fallbacks, func(i, j int) bool {
+			return precedes(fallbacks[i], fallbacks[j])
+		})
+	}
+	return primaries, fallbacksByPrimaryID, aliases
 }
 
-func addWithOrderPrevention(
-	extensionRuntimeInfoWithDependenciesInfos []*extensionRuntimeInfoWithDependenciesInfo,
+// insertFallbacks places fallback extensions right after their primary extensions.
+func insertFallbacks(
 	sortedInfos []extensionRuntimeInfo,
+	fallbacksByPrimaryID map[string][]extensionRuntimeInfo,
+	expectedLen int,
+) ([]extensionRuntimeInfo, error) {
+	if len(fallbacksByPrimaryID) == 0 {
+		return sortedInfos, nil
+	}
+	res := make([]extensionRuntimeInfo, 0, expectedLen)
+	var add func(info extensionRuntimeInfo)
+	add = func(info extensionRuntimeInfo) {
+		res = append(res, info)
+		fallbacks := fallbacksByPrimaryID[info.cfg.ID]
+		delete(fallbacksByPrimaryID, info.cfg.ID)
+		for _, fallback := range fallbacks {
+			add(fallback)
+		}
+	}
+	for _, info := range sortedInfos {
+		add(info)
+	}
+	for primaryID, fallbacks := range fallbacksByPrimaryID {
+		return nil, fmt.Errorf(
+			`circular fallback found during plugins extensions priority resolution for extensionID "%s" with fallback "%s"`,
+			primaryID,
+			fallbacks[0].cfg.ID,
+		)
+	}
+	return res, nil
+}
+
+// sortTopologically orders extensions via the Kahn's algorithm: among extensions which dependencies are already added,
+// the one preceding others according to precedes is added first.
+// Extensions left unsorted are on circular dependencies or depend on them, so the cycle is returned as the error.
+func sortTopologically(
+	infos []extensionRuntimeInfo,
+	dependenciesByExtensionID map[string]*Set[string],
 ) ([]extensionRuntimeInfo, error) {
-	var lastlyAdded []*extensionRuntimeInfoWithDependenciesInfo
-	first := true
-	for first || len(lastlyAdded) > 0 {
-		first = false
-		for _, info := range lastlyAdded {
-			for _, dependenciesInfo := range info.dependants.Values() {
-				dependenciesInfo.unsatisfiedDependencies.Remove(info.info.cfg.ID)
+	nodes := make([]orderNode, len(infos))
+	nodeByExtensionID := make(map[string]*orderNode, len(infos))
+	for i, info := range infos {
+		nodes[i].info = info
+		nodeByExtensionID[info.cfg.ID] = &nodes[i]
+	}
+
+	ready := make(readyNodes, 0, len(infos))
+	for i := range nodes {
+		node := &nodes[i]
+		for _, dependencyID := range dependenciesByExtensionID[node.info.cfg.ID].Values() {
+			if dependency, ok := nodeByExtensionID[dependencyID]; ok {
+				dependency.dependants = append(dependency.dependants, node)
+				node.unsatisfiedDependencies++
 			}
 		}
-		lastlyAdded = nil
+		if node.unsatisfiedDependencies == 0 {
+			ready = append(ready, node)
+		}
+	}
+	heap.Init(&ready)
 
-		// reverse extensionRuntimeInfos so that we'll add them in reversed order according to dependencies
-		extensionRuntimeInfoWithDependenciesInfosReversed := make(
-			[]*extensionRuntimeInfoWithDependenciesInfo,
-			len(extensionRuntimeInfoWithDependenciesInfos),
-		)
-		copy(extensionRuntimeInfoWithDependenciesInfosReversed, extensionRuntimeInfoWithDependenciesInfos)
-		slices.Reverse(extensionRuntimeInfoWithDependenciesInfosReversed)
-
-		for _, info := range extensionRuntimeInfoWithDependenciesInfosReversed {
-			if !info.processed && info.unsatisfiedDependencies.Len() == 0 {
-				sortedInfos = append(sortedInfos, info.info)
-				info.processed = true
-				lastlyAdded = append(lastlyAdded, info)
+	sortedInfos := make([]extensionRuntimeInfo, 0, len(infos))
+	for ready.Len() > 0 {
+		node := heap.Pop(&ready).(*orderNode)
+		sortedInfos = append(sortedInfos, node.info)
+		for _, dependant := range node.dependants {
+			dependant.unsatisfiedDependencies--
+			if dependant.unsatisfiedDependencies == 0 {
+				heap.Push(&ready, dependant)
 			}
 		}
 	}
 
-	// here could be check that all non-optional dependencies are satisfied
+	if len(sortedInfos) < len(infos) {
+		return nil, circularDependencyError(nodes, dependenciesByExtensionID)
+	}
+
+	// required dependencies are checked before ordering, see checkRequiredExtensions
 	return sortedInfos, nil
 }
 
-func createRecursiveDependenciesByExtensionID(orig []extensionRuntimeInfo) (map[string]*Set[string], error) {
-	recursiveDependenciesByName := make(map[string]*Set[string])
-	// process AfterExtensionIDs
+// circularDependencyError describes the cycle of dependencies among nodes left unsorted by sortTopologically.
+func circularDependencyError(nodes []orderNode, dependenciesByExtensionID map[string]*Set[string]) error {
+	unsortedDependenciesByExtensionID := make(map[string]*Set[string])
+	for _, node := range nodes {
+		if node.unsatisfiedDependencies > 0 {
+			unsortedDependenciesByExtensionID[node.info.cfg.ID] = dependenciesByExtensionID[node.info.cfg.ID]
+		}
+	}
+	cycle := findDependencyCycle(unsortedDependenciesByExtensionID)
+	return fmt.Errorf(
+		`circular transitive dependency found during plugins extensions`+
+			` priority resolution for extensionID "%s". Circular dependency on the extensionID="%s", cycle: %s`,
+		cycle[0],
+		cycle[1],
+		strings.Join(cycle, " after "),
+	)
+}
+
+// createDependenciesByExtensionID returns IDs of extensions which should be executed before the extension
+// according to declared constraints. Constraints of replaced extensions are applied to the replacing ones.
+func createDependenciesByExtensionID(
+	orig []extensionRuntimeInfo,
+	replaced []extensionRuntimeInfo,
+	aliases map[string]string,
+) (map[string]*Set[string], error) {
+	dependenciesByExtensionID := make(map[string]*Set[string])
 	for _, info := range orig {
-		if _, ok := recursiveDependenciesByName[info.cfg.ID]; ok {
+		if _, ok := dependenciesByExtensionID[info.cfg.ID]; ok {
 			return nil, fmt.Errorf("extension duplication found with extension ID %s", info.cfg.ID)
 		}
-		afterSet := NewSetFromSlice[string](info.cfg.AfterExtensionIDs)
-		recursiveDependenciesByName[info.cfg.ID] = afterSet
+		dependenciesByExtensionID[info.cfg.ID] = NewSet[string]()
+	}
+	constrained := make([]extensionRuntimeInfo, 0, len(orig)+len(replaced))
+	constrained = append(constrained, orig...)
+	constrained = append(constrained, replaced...)
+	// process AfterExtensionIDs
+	for _, info := range constrained {
+		ownerID := resolveAlias(info.cfg.ID, aliases)
+		dependencies, ok := dependenciesByExtensionID[ownerID]
+		if !ok {
+			continue
+		}
+		afterIDs := append(resolveAliases(info.cfg.AfterExtensionIDs, aliases), resolveAliases(info.cfg.RequiresExtensionIDs, aliases)...)
+		for _, extensionID := range afterIDs {
+			// the replacing extension could reference the replaced one
+			if extensionID != ownerID {
+				dependencies.Add(extensionID)
+			}
+		}
 	}
 	// process BeforeExtensionIDs
-	for _, info := range orig {
-		beforeSet := NewSetFromSlice[string](info.cfg.BeforeExtensionIDs)
-		for _, extensionID := range beforeSet.Values() {
-			if dependencies, ok := recursiveDependenciesByName[extensionID]; ok {
-				dependencies.Add(info.cfg.ID)
+	for _, info := range constrained {
+		extensionID := resolveAlias(info.cfg.ID, aliases)
+		for _, beforeExtensionID := range resolveAliases(info.cfg.BeforeExtensionIDs, aliases) {
+			if dependencies, ok := dependenciesByExtensionID[beforeExtensionID]; ok && beforeExtensionID != extensionID {
+				dependencies.Add(extensionID)
 			}
 		}
 	}
 
-	// process transitive dependencies
-	for extensionID, dependenciesIDs := range recursiveDependenciesByName {
-		additionalTransitiveDependencies, err := getTransitiveDependencyIDs(
-			extensionID,
-			recursiveDependenciesByName,
-			NewSetFromSlice[string](dependenciesIDs.Values()),
-			NewSet[string](),
-		)
-		if err != nil {
-			return nil, err
-		}
-		dependenciesIDs.AddAll(additionalTransitiveDependencies)
+	return dependenciesByExtensionID, nil
+}
+
+// resolveAliases replaces IDs of missing primary extensions by IDs of their fallbacks.
+func resolveAliases(extensionIDs []string, aliases map[string]string) []string {
+	if len(aliases) == 0 {
+		return extensionIDs
+	}
+	res := make([]string, 0, len(extensionIDs))
+	for _, extensionID := range extensionIDs {
+		res = append(res, resolveAlias(extensionID, aliases))
 	}
-	return recursiveDependenciesByName, nil
+	return res
 }
 
-func getTransitiveDependencyIDs(
-	extensionID string,
-	recursiveDependenciesByName map[string]*Set[string],
-	unprocessedExtensionIDs *Set[string],
-	processingExtensionIDs *Set[string],
-) (*Set[string], error) {
-	additionalDependencies := NewSet[string]()
-	unprocessedExtensionIDs.Remove(extensionID)
-	processingExtensionIDs.Add(extensionID)
-	if dependencyIDs, ok := recursiveDependenciesByName[extensionID]; ok {
-		additionalDependencies.AddAll(dependencyIDs)
-		for _, dependencyID := range dependencyIDs.Values() {
-			if processingExtensionIDs.Contains(dependencyID) {
-				return nil, fmt.Errorf(
-					`circular transitive dependency found during plugins extensions`+
-						` priority resolution for extensionID "%s". Circular dependency on the extensionID="%s"`,
-					extensionID,
-					dependencyID)
-			}
-			if unprocessedExtensionIDs.Contains(dependencyID) {
-				recursiveDependencies, err := getTransitiveDependencyIDs(dependencyID, recursiveDependenciesByName, unprocessedExtensionIDs, processingExtensionIDs)
-				if err != nil {
-					return nil, err
-				}
-				additionalDependencies.AddAll(recursiveDependencies)
+// findDependencyCycle returns the path of declared dependencies which ends with its first extension ID,
+// or nil if there are no circular dependencies.
+func findDependencyCycle(dependenciesByName map[string]*Set[string]) []string {
+	extensionIDs := make([]string, 0, len(dependenciesByName))
+	for extensionID := range dependenciesByName {
+		extensionIDs = append(extensionIDs, extensionID)
+	}
+	sort.Strings(extensionIDs)
+
+	visited := NewSet[string]()
+	var path []string
+	// pathIndexByExtensionID is the position of the extension in the path being visited
+	pathIndexByExtensionID := make(map[string]int)
+	var visit func(extensionID string) []string
+	visit = func(extensionID string) []string {
+		if i, ok := pathIndexByExtensionID[extensionID]; ok {
+			return append(path[i:len(path):len(path)], extensionID)
+		}
+		if visited.Contains(extensionID) {
+			return nil
+		}
+		visited.Add(extensionID)
+		dependencies, ok := dependenciesByName[extensionID]
+		if !ok {
+			return nil
+		}
+		dependencyIDs := dependencies.Values()
+		sort.Strings(dependencyIDs)
+		pathIndexByExtensionID[extensionID] = len(path)
+		path = append(path, extensionID)
+		for _, dependencyID := range dependencyIDs {
+			if cycle := visit(dependencyID); cycle != nil {
+				return cycle
 			}
 		}
+		path = path[:len(path)-1]
+		delete(pathIndexByExtensionID, extensionID)
+		return nil
+	}
+	for _, extensionID := range extensionIDs {
+		if cycle := visit(extensionID); cycle != nil {
+			return cycle
+		}
 	}
-	processingExtensionIDs.Remove(extensionID)
-	return additionalDependencies, nil
+	return nil
 }
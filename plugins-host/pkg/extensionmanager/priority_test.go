@@ -0,0 +1,119 @@
+package extensionmanager
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestOrderPriorities(t *testing.T) {
+	infos := []extensionRuntimeInfo{
+		{loadIndex: 2, cfg: pluginstypes.ExtensionConfig{ID: "b.low", Priority: -1}},
+		{loadIndex: 2, cfg: pluginstypes.ExtensionConfig{ID: "b.high", Priority: 10}},
+		{loadIndex: 1, cfg: pluginstypes.ExtensionConfig{ID: "a.z"}},
+		{loadIndex: 1, cfg: pluginstypes.ExtensionConfig{ID: "a.y"}},
+		{loadIndex: 2, cfg: pluginstypes.ExtensionConfig{ID: "b.x"}},
+		{loadIndex: 0, cfg: pluginstypes.ExtensionConfig{ID: "host"}},
+		// constraints take precedence over priorities
+		{loadIndex: 2, cfg: pluginstypes.ExtensionConfig{ID: "b.first", Priority: 100, AfterExtensionIDs: []string{"b.low"}}},
+	}
+	expected := "[b.high host a.y a.z b.x b.low b.first]"
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := append([]extensionRuntimeInfo(nil), infos...)
+		r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		ordered, err := OrderExtensionRuntimeInfo(shuffled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, info := range ordered {
+			ids = append(ids, info.cfg.ID)
+		}
+		if got := fmt.Sprint(ids); got != expected {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestUpdateExtensionsOrderOnlyTouched(t *testing.T) {
+	m := NewWSManager()
+	m.extensionRuntimeInfoByExtensionPointIDs["touched"] = []extensionRuntimeInfo{
+		{cfg: pluginstypes.ExtensionConfig{ID: "b"}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "a"}},
+	}
+	m.extensionRuntimeInfoByExtensionPointIDs["untouched"] = []extensionRuntimeInfo{
+		{cfg: pluginstypes.ExtensionConfig{ID: "b"}},
+		{cfg: pluginstypes.ExtensionConfig{ID: "a"}},
+	}
+	m.unorderedExtensionPointIDs.Add("touched")
+	if err := m.updateExtensionsOrder(); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.extensionRuntimeInfoByExtensionPointIDs["touched"][0].cfg.ID; got != "a" {
+		t.Fatalf("touched extension point is not ordered, first is %s", got)
+	}
+	if got := m.extensionRuntimeInfoByExtensionPointIDs["untouched"][0].cfg.ID; got != "b" {
+		t.Fatalf("untouched extension point is reordered, first is %s", got)
+	}
+	if m.unorderedExtensionPointIDs.Len() != 0 {
+		t.Fatal("ordered extension point is still marked as unordered")
+	}
+}
+
+// benchmarkInfos returns extensions with chains of After constraints, random Before constraints and priorities.
+func benchmarkInfos(n int) []extensionRuntimeInfo {
+	r := rand.New(rand.NewSource(1))
+	infos := make([]extensionRuntimeInfo, n)
+	for i := range infos {
+		cfg := pluginstypes.ExtensionConfig{ID: fmt.Sprintf("ext.%d", i), Priority: r.Intn(10)}
+		if i > 0 && i%4 != 0 {
+			cfg.AfterExtensionIDs = []string{fmt.Sprintf("ext.%d", i-1)}
+		}
+		if i+10 < n && r.Intn(3) == 0 {
+			cfg.BeforeExtensionIDs = []string{fmt.Sprintf("ext.%d", i+1+r.Intn(n-i-1))}
+		}
+		infos[i] = extensionRuntimeInfo{loadIndex: r.Intn(5), cfg: cfg}
+	}
+	r.Shuffle(n, func(i, j int) { infos[i], infos[j] = infos[j], infos[i] })
+	return infos
+}
+
+func BenchmarkOrderExtensionRuntimeInfo(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000, 10000} {
+		infos := benchmarkInfos(n)
+		b.Run(fmt.Sprintf("extensions=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := OrderExtensionRuntimeInfo(infos); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOrderExtensionRuntimeInfoChain orders extensions forming a single chain of After constraints.
+func BenchmarkOrderExtensionRuntimeInfoChain(b *testing.B) {
+	for _, n := range []int{1000, 5000, 20000} {
+		infos := make([]extensionRuntimeInfo, n)
+		for i := range infos {
+			cfg := pluginstypes.ExtensionConfig{ID: fmt.Sprintf("ext.%d", i)}
+			if i > 0 {
+				cfg.AfterExtensionIDs = []string{fmt.Sprintf("ext.%d", i-1)}
+			}
+			infos[i] = extensionRuntimeInfo{cfg: cfg}
+		}
+		b.Run(fmt.Sprintf("extensions=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := OrderExtensionRuntimeInfo(infos); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package extensionmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+)
+
+// pluginProcess holds information about the plugin process started by the WSManager.
+type pluginProcess struct {
+	pid         int
+	started     chan struct{}
+	startedOnce sync.Once
+}
+
+func newPluginProcess() *pluginProcess {
+	return &pluginProcess{started: make(chan struct{})}
+}
+
+// setStarted records the started process. The p could be nil when the process failed to start.
+// Only the first call takes effect, so it could be deferred to unblock verifyPeer whatever happens with the start.
+func (pp *pluginProcess) setStarted(p *os.Process) {
+	pp.startedOnce.Do(func() {
+		if p != nil {
+			pp.pid = p.Pid
+		}
+		close(pp.started)
+	})
+}
+
+// forgetPluginProcess removes the process started for the secret, so the secret can't be used anymore.
+func (m *WSManager) forgetPluginProcess(secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pluginProcessBySecret, secret)
+}
+
+// ErrPeerRejected is returned when the plugin connection is not opened by the plugin process started by the host.
+var ErrPeerRejected = errors.New("plugin peer rejected")
+
+// verifyPeer checks that the connection was opened by the process started for the secret.
+//
+// The check is performed only for transports able to identify the peer process.
+// Such connections with secrets of processes which were not started by the host are rejected.
+// The secret of the verified process can't be used by other connections.
+func (m *WSManager) verifyPeer(c transport.Conn, secret string) error {
+	peerConn, ok := c.(transport.PeerCredentialsConn)
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	process, ok := m.pluginProcessBySecret[secret]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: unknown plugin secret", ErrPeerRejected)
+	}
+
+	<-process.started
+	if process.pid == 0 {
+		return fmt.Errorf("%w: the plugin process is not started", ErrPeerRejected)
+	}
+	peerPID, err := peerConn.PeerPID()
+	switch {
+	case errors.Is(err, transport.ErrPeerCredentialsUnsupported):
+	case err != nil:
+		return fmt.Errorf("get peer credentials: %w", err)
+	case peerPID != process.pid:
+		return fmt.Errorf("%w: peer process id %d doesn't match the started plugin process id %d", ErrPeerRejected, peerPID, process.pid)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pluginProcessBySecret[secret] != process {
+		return fmt.Errorf("%w: the plugin secret is already used", ErrPeerRejected)
+	}
+	delete(m.pluginProcessBySecret, secret)
+	return nil
+}
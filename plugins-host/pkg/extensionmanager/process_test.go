@@ -0,0 +1,134 @@
+package extensionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/unixsocket"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestVerifyPeerRejectsForeignProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are checked on Linux only")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := NewWSManager().WithUnixSocket().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := m.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	events := m.Events(16)
+	defer events.Close()
+
+	// the process started for the secret is not the test process connecting to the host
+	process := newPluginProcess()
+	process.pid = os.Getpid() + 1
+	close(process.started)
+	m.mu.Lock()
+	m.pluginProcessBySecret["started"] = process
+	m.mu.Unlock()
+
+	for _, secret := range []string{"started", "unknown"} {
+		c, err := unixsocket.NewDialer(m.lis.Addr().String()).Dial(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(pluginstypes.RegisterPluginData{PluginID: "plugin.foreign", Secret: secret})
+		msg, _ := json.Marshal(pluginstypes.Message{Type: pluginstypes.CommandTypeRegisterPlugin, MsgID: secret, Data: data, IsFinal: true})
+		if err := c.WriteMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ReadMessage(); err == nil {
+			t.Fatalf("%s: expected the connection to be closed without acknowledgement", secret)
+		}
+		c.Close()
+
+		select {
+		case e := <-events.C():
+			if e.Type != EventProtocolError || !errors.Is(e.Err, ErrPeerRejected) {
+				t.Fatalf("%s: expected rejected registration, got %s: %v", secret, e.Type, e.Err)
+			}
+		case <-ctx.Done():
+			t.Fatalf("%s: no rejection event", secret)
+		}
+	}
+	if len(m.Introspect().Plugins) != 0 {
+		t.Fatal("foreign plugins should not be registered")
+	}
+}
+
+func TestVerifyPeerSecretIsUsedOnce(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are checked on Linux only")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := NewWSManager().WithUnixSocket().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := m.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// the test process plays the started plugin process
+	process := newPluginProcess()
+	process.setStarted(&os.Process{Pid: os.Getpid()})
+	m.mu.Lock()
+	m.pluginProcessBySecret["started"] = process
+	m.mu.Unlock()
+
+	var conns []transport.Conn
+	for i, expectRegistered := range []bool{true, false} {
+		c, err := unixsocket.NewDialer(m.lis.Addr().String()).Dial(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+		data, _ := json.Marshal(pluginstypes.RegisterPluginData{PluginID: "plugin.A", Secret: "started"})
+		msg, _ := json.Marshal(pluginstypes.Message{Type: pluginstypes.CommandTypeRegisterPlugin, MsgID: "register", Data: data, IsFinal: true})
+		if err := c.WriteMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ReadMessage(); (err == nil) != expectRegistered {
+			t.Fatalf("connection %d: expected registered %v, got %v", i, expectRegistered, err)
+		}
+	}
+	m.mu.Lock()
+	disconnected := m.pluginDisconnectedByPluginID["plugin.A"]
+	m.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	select {
+	case <-disconnected:
+	case <-ctx.Done():
+		t.Fatal("the registered plugin is not disconnected")
+	}
+
+	// the process entry is removed when the plugin process exits before the registration too
+	if err := m.LoadPlugins(ctx, "true"); err == nil {
+		t.Fatal("error should be returned for the plugin exited before the registration")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pluginProcessBySecret) != 0 {
+		t.Fatalf("plugin processes are not removed: %v", m.pluginProcessBySecret)
+	}
+}
@@ -0,0 +1,101 @@
+package extensionmanager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReplacementConflict is returned when several extensions replace the same extension,
+// or extensions replace each other, see ExtensionConfig.Replaces.
+var ErrReplacementConflict = errors.New("extension replacement conflict")
+
+// splitReplaced removes extensions replaced by other ones.
+// It returns the rest extensions, the replaced ones, and IDs of the replacing extensions by IDs of the replaced ones.
+// When the replacing extension is replaced too, the last replacing extension in the chain is returned.
+func splitReplaced(orig []extensionRuntimeInfo) (
+	kept []extensionRuntimeInfo,
+	replaced []extensionRuntimeInfo,
+	replacerIDs map[string]string,
+	err error,
+) {
+	replacerByReplacedID := make(map[string]extensionRuntimeInfo)
+	for _, info := range orig {
+		replacedID := info.cfg.Replaces
+		if replacedID == "" {
+			continue
+		}
+		if other, ok := replacerByReplacedID[replacedID]; ok {
+			return nil, nil, nil, fmt.Errorf(
+				`extension "%s" of %s and extension "%s" of %s both replace extension "%s": %w`,
+				other.cfg.ID,
+				describePlugin(other.pluginID),
+				info.cfg.ID,
+				describePlugin(info.pluginID),
+				replacedID,
+				ErrReplacementConflict,
+			)
+		}
+		replacerByReplacedID[replacedID] = info
+	}
+	if len(replacerByReplacedID) == 0 {
+		return orig, nil, nil, nil
+	}
+
+	replacerIDs = make(map[string]string, len(replacerByReplacedID))
+	for replacedID := range replacerByReplacedID {
+		replacerID := replacedID
+		for i := 0; ; i++ {
+			replacer, ok := replacerByReplacedID[replacerID]
+			if !ok {
+				break
+			}
+			if i == len(replacerByReplacedID) {
+				return nil, nil, nil, fmt.Errorf(
+					`extension "%s" is replaced by itself via the chain of replacements: %w`,
+					replacedID,
+					ErrReplacementConflict,
+				)
+			}
+			replacerID = replacer.cfg.ID
+		}
+		replacerIDs[replacedID] = replacerID
+	}
+
+	for _, info := range orig {
+		if _, ok := replacerIDs[info.cfg.ID]; ok {
+			replaced = append(replaced, info)
+		} else {
+			kept = append(kept, info)
+		}
+	}
+	return kept, replaced, replacerIDs, nil
+}
+
+// replacementAliases returns IDs of the replacing extensions by IDs of the replaced ones for already ordered extensions.
+func replacementAliases(infos []extensionRuntimeInfo) map[string]string {
+	var aliases map[string]string
+	for _, info := range infos {
+		if info.cfg.Replaces != "" {
+			if aliases == nil {
+				aliases = make(map[string]string)
+			}
+			aliases[info.cfg.Replaces] = info.cfg.ID
+		}
+	}
+	return aliases
+}
+
+// resolveAlias returns the ID of the extension taking the place of the extension with the given ID.
+func resolveAlias(extensionID string, aliases map[string]string) string {
+	if alias, ok := aliases[extensionID]; ok {
+		return alias
+	}
+	return extensionID
+}
+
+func describePlugin(pluginID string) string {
+	if pluginID == "" {
+		return "the host"
+	}
+	return fmt.Sprintf(`plugin "%s"`, pluginID)
+}
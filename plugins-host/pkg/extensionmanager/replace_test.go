@@ -0,0 +1,109 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestOrderReplacements(t *testing.T) {
+	a := pluginstypes.ExtensionConfig{ID: "a"}
+	def := pluginstypes.ExtensionConfig{ID: "default", AfterExtensionIDs: []string{"a"}, Priority: -1}
+	b := pluginstypes.ExtensionConfig{ID: "b", AfterExtensionIDs: []string{"default"}}
+	custom := pluginstypes.ExtensionConfig{ID: "custom", Replaces: "default", Priority: 1}
+	fallback := pluginstypes.ExtensionConfig{ID: "fallback", FallbackFor: "default"}
+
+	if got := fmt.Sprint(orderedIDs(t, b, custom, def, a, fallback)); got != "[a custom fallback b]" {
+		t.Fatalf("replacing extension should take the replaced one's place, got %s", got)
+	}
+
+	_, err := OrderExtensionRuntimeInfo([]extensionRuntimeInfo{
+		{cfg: def},
+		{pluginID: "plugin.A", cfg: custom},
+		{pluginID: "plugin.B", cfg: pluginstypes.ExtensionConfig{ID: "other", Replaces: "default"}},
+	})
+	if !errors.Is(err, ErrReplacementConflict) {
+		t.Fatalf("expected ErrReplacementConflict, got %v", err)
+	}
+}
+
+func TestExecuteReplacement(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.getRandomNumber.default",
+		ExtensionPointID: "getRandomNumber",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "4", nil
+	})
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.getRandomNumber",
+			ExtensionPointID: "getRandomNumber",
+			Replaces:         "app.getRandomNumber.default",
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			return "6", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "getRandomNumber", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	if fmt.Sprint(outs) != "[6]" {
+		t.Fatalf("only the replacing extension should be executed, got %v", outs)
+	}
+}
+
+func TestExecuteReplacementInheritsConstraints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:                "x.replaced",
+		ExtensionPointID:  "steps",
+		AfterExtensionIDs: []string{"z.first"},
+	}, func(ctx context.Context, in string) (string, error) {
+		return "x.replaced", nil
+	})
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		for _, cfg := range []pluginstypes.ExtensionConfig{
+			{ID: "a.replacer", Replaces: "x.replaced"},
+			{ID: "z.first"},
+		} {
+			cfg := cfg
+			cfg.ExtensionPointID = "steps"
+			p.Extension(cfg, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+				return cfg.ID, nil
+			}))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "steps", "") {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	if fmt.Sprint(outs) != "[z.first a.replacer]" {
+		t.Fatalf("replacing extension should be executed after z.first as the replaced one, got %v", outs)
+	}
+}
@@ -0,0 +1,92 @@
+package extensionmanager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestRetryIdempotentExtensions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	var hostCalls, pluginCalls atomic.Int32
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:                "app.flaky",
+		ExtensionPointID:  "flaky",
+		AfterExtensionIDs: []string{"plugina.flaky"},
+		Idempotent:        true,
+	}, func(ctx context.Context, in string) (string, error) {
+		if hostCalls.Add(1)%3 != 0 {
+			return "", pluginstypes.NewError(pluginstypes.CodeUnavailable, "service is not ready")
+		}
+		return "host", nil
+	})
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.fragile",
+		ExtensionPointID: "fragile",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "", pluginstypes.NewError(pluginstypes.CodeUnavailable, "service is not ready")
+	})
+
+	var plugin *plugins.Plugin
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		plugin = p
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "plugina.flaky",
+			ExtensionPointID: "flaky",
+			Idempotent:       true,
+		}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+			if pluginCalls.Add(1) == 1 {
+				return "", pluginstypes.NewError(pluginstypes.CodeUnavailable, "service is not ready")
+			}
+			return "plugin", nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := pluginstypes.WithRetry(pluginstypes.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	})
+	var got []string
+	var attempts []int
+	for r := range ExecuteExtensions[string, string](ctx, m, "flaky", "", policy) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		got = append(got, r.Out)
+		attempts = append(attempts, len(r.Meta.Attempts))
+		if last := r.Meta.Attempts[len(r.Meta.Attempts)-1]; last.Error != nil {
+			t.Fatalf("the last attempt of %s failed: %v", r.Meta.ExtensionID, last.Error)
+		}
+	}
+	if fmt.Sprint(got) != "[plugin host]" || fmt.Sprint(attempts) != "[2 3]" {
+		t.Fatalf("unexpected results %v with attempts %v", got, attempts)
+	}
+
+	for r := range ExecuteExtensions[string, string](ctx, m, "fragile", "", policy) {
+		if r.Err == nil || len(r.Meta.Attempts) != 1 {
+			t.Fatalf("non-idempotent extension must not be retried: %v, %d attempts", r.Err, len(r.Meta.Attempts))
+		}
+	}
+
+	// the policy requested by the plugin is applied by the host
+	hostCalls.Store(0)
+	for r := range plugins.ExecuteExtensionsOf[string, string](ctx, plugin, "flaky", "", policy) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Meta.ExtensionID == "app.flaky" && len(r.Meta.Attempts) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(r.Meta.Attempts))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestExecuteWithSelector(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		for id, labels := range map[string]map[string]string{
+			"lint.vet":        {"lang": "go"},
+			"lint.sqlfluff":   {"lang": "sql"},
+			"lint.experiment": {"lang": "go", "stage": "experimental"},
+			"lint.shellcheck": {"lang": "sh"},
+		} {
+			id := id
+			p.Extension(pluginstypes.ExtensionConfig{
+				ID:               id,
+				ExtensionPointID: "lint",
+				Labels:           labels,
+			}, plugins.Implementation(func(ctx context.Context, in string) (string, error) {
+				return id, nil
+			}))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outs []string
+	for r := range ExecuteExtensions[string, string](ctx, m, "lint", "", pluginstypes.WithSelector("lang in (go,sql), stage!=experimental")) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		outs = append(outs, r.Out)
+	}
+	if fmt.Sprint(outs) != "[lint.sqlfluff lint.vet]" {
+		t.Fatalf("unexpected selected extensions %v", outs)
+	}
+
+	for r := range ExecuteExtensions[string, string](ctx, m, "lint", "", pluginstypes.WithSelector("lang in go")) {
+		if !errors.Is(r.Err, pluginstypes.ErrInvalidSelector) {
+			t.Fatalf("expected ErrInvalidSelector, got %v", r.Err)
+		}
+	}
+}
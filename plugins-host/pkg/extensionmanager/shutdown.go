@@ -0,0 +1,64 @@
+package extensionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// Shutdown executes the system.shutdown extension point, requests all connected plugins to shut down,
+// waits until they disconnect and stops accepting new plugins.
+//
+// Plugins invoke their OnShutdown hooks before disconnecting, so they could still execute host's extension points.
+// If the ctx is done before all plugins disconnect, remaining connections are closed and the context error is returned.
+func (m *WSManager) Shutdown(ctx context.Context) error {
+	m.notifySystem(ctx, pluginstypes.ExtensionPointShutdown, pluginstypes.ShutdownEvent{})
+
+	m.mu.Lock()
+	m.shuttingDown = true
+	conns := make(map[string]transport.Conn, len(m.channelByPluginID))
+	for pluginID, c := range m.channelByPluginID {
+		conns[pluginID] = c
+	}
+	disconnectedByPluginID := make(map[string]chan struct{}, len(m.pluginDisconnectedByPluginID))
+	for pluginID, disconnected := range m.pluginDisconnectedByPluginID {
+		disconnectedByPluginID[pluginID] = disconnected
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for pluginID, c := range conns {
+		msg := pluginstypes.Message{
+			Type:    pluginstypes.CommandTypeShutdown,
+			IsFinal: true,
+		}
+		if err := m.writeResponse(msg, c); err != nil {
+			errs = append(errs, fmt.Errorf("request plugin %s shutdown: %w", pluginID, err))
+		}
+	}
+
+	for pluginID, disconnected := range disconnectedByPluginID {
+		select {
+		case <-disconnected:
+		case <-ctx.Done():
+			_ = conns[pluginID].Close()
+			errs = append(errs, fmt.Errorf("await plugin %s shutdown: %w", pluginID, ctx.Err()))
+		}
+	}
+
+	if m.lis != nil {
+		if err := m.lis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close listener: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *WSManager) isShuttingDown() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.shuttingDown
+}
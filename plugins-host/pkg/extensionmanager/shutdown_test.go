@@ -0,0 +1,95 @@
+package extensionmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestPluginLifecycleHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	Extension[string, string](m, pluginstypes.ExtensionConfig{
+		ID:               "app.warmup",
+		ExtensionPointID: "warmup",
+	}, func(ctx context.Context, in string) (string, error) {
+		return "warm " + in, nil
+	})
+
+	registered := make(chan string, 1)
+	shutdown := make(chan struct{})
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.OnRegistered(func(ctx context.Context) {
+			for r := range plugins.ExecuteExtensionsOf[string, string](ctx, p, "warmup", "cache") {
+				if r.Err != nil {
+					t.Error(r.Err)
+					return
+				}
+				registered <- r.Out
+			}
+		})
+		p.OnShutdown(func(ctx context.Context) {
+			close(shutdown)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case out := <-registered:
+		if out != "warm cache" {
+			t.Fatalf("unexpected warmup result %s", out)
+		}
+	case <-ctx.Done():
+		t.Fatal("OnRegistered hook was not invoked")
+	}
+
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-shutdown:
+	default:
+		t.Fatal("OnShutdown hook should be invoked before the plugin disconnects")
+	}
+}
+
+func TestShutdownWithUnresponsivePlugin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	release := make(chan struct{})
+	defer close(release)
+	err := m.LoadInProcess(ctx, "plugin.A", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "a.shutdown",
+			ExtensionPointID: pluginstypes.ExtensionPointShutdown,
+		}, plugins.Implementation(func(ctx context.Context, in pluginstypes.ShutdownEvent) (struct{}, error) {
+			// the plugin never answers the shutdown notification
+			<-release
+			return struct{}{}, nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shutdownCancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Shutdown(shutdownCtx)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("Shutdown doesn't return after its context is done")
+	}
+}
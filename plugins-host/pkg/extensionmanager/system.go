@@ -0,0 +1,100 @@
+package extensionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// notifySystem executes the reserved system extension point with the event.
+//
+// Failures of system extensions are logged only, so they don't affect the manager itself.
+func (m *WSManager) notifySystem(ctx context.Context, extensionPointID string, event any) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		m.logger.Error(
+			"marshal system event",
+			slog.String("extensionPointID", extensionPointID),
+			slog.String("err", err.Error()),
+		)
+		return
+	}
+	results := ExecuteExtensions[json.RawMessage, json.RawMessage](ctx, m, extensionPointID, data)
+	for result := range results {
+		if result.Err != nil {
+			m.logger.Error(
+				"system extension failed",
+				slog.String("extensionPointID", extensionPointID),
+				slog.String("err", result.Err.Error()),
+			)
+		}
+	}
+}
+
+func (m *WSManager) pluginRegisteredEvent(pluginID string) pluginstypes.PluginRegisteredEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event := pluginstypes.PluginRegisteredEvent{PluginID: pluginID}
+	for _, infos := range m.extensionRuntimeInfoByExtensionPointIDs {
+		for _, info := range infos {
+			if info.pluginID == pluginID {
+				event.ExtensionIDs = append(event.ExtensionIDs, info.cfg.ID)
+			}
+		}
+	}
+	return event
+}
+
+// unregisterPlugin removes extensions provided via the closed connection of the plugin.
+func (m *WSManager) unregisterPlugin(pluginID string, c transport.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.channelByPluginID[pluginID] == c {
+		delete(m.channelByPluginID, pluginID)
+		delete(m.pluginDisconnectedByPluginID, pluginID)
+	}
+	for extensionPointID, infos := range m.extensionRuntimeInfoByExtensionPointIDs {
+		// filter into the new slice, as the old one could be used by running ExecuteExtensions
+		filtered := make([]extensionRuntimeInfo, 0, len(infos))
+		for _, info := range infos {
+			if info.conn != c {
+				filtered = append(filtered, info)
+			}
+		}
+		if len(filtered) != len(infos) {
+			m.unorderedExtensionPointIDs.Add(extensionPointID)
+		}
+		m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID] = filtered
+	}
+	for extensionPointID, disabled := range m.disabledExtensionsByExtensionPointID {
+		filtered := make([]disabledExtension, 0, len(disabled))
+		for _, d := range disabled {
+			if d.info.conn != c {
+				filtered = append(filtered, d)
+			}
+		}
+		if len(filtered) != len(disabled) {
+			m.unorderedExtensionPointIDs.Add(extensionPointID)
+		}
+		m.disabledExtensionsByExtensionPointID[extensionPointID] = filtered
+	}
+	if m.shuttingDown {
+		// all plugins are unregistered during the shutdown, so violations are expected
+		return
+	}
+	if m.pluginsOrdered {
+		// restore extensions replaced by the plugin and defaults
+		if err := m.reorderChangedExtensionPoints(); err != nil {
+			m.logger.Error(
+				"order extensions of the unregistered plugin",
+				slog.String("pluginID", pluginID),
+				slog.String("err", err.Error()),
+			)
+		}
+	}
+	// violations are published as events
+	_ = m.checkCardinalities()
+}
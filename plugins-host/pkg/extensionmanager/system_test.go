@@ -0,0 +1,125 @@
+package extensionmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestSystemExtensionPoints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := NewWSManager().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := make(chan pluginstypes.PluginRegisteredEvent, 1)
+	Extension[pluginstypes.PluginRegisteredEvent, struct{}](m, pluginstypes.ExtensionConfig{
+		ID:               "app.monitoring.registered",
+		ExtensionPointID: pluginstypes.ExtensionPointPluginRegistered,
+	}, func(ctx context.Context, in pluginstypes.PluginRegisteredEvent) (struct{}, error) {
+		registered <- in
+		return struct{}{}, nil
+	})
+
+	failed := make(chan pluginstypes.PluginFailedEvent, 1)
+	shutdown := make(chan struct{}, 1)
+	err = m.LoadInProcess(ctx, "plugin.monitoring", func(p *plugins.Plugin) {
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "monitoring.failed",
+			ExtensionPointID: pluginstypes.ExtensionPointPluginFailed,
+		}, plugins.Implementation(func(ctx context.Context, in pluginstypes.PluginFailedEvent) (struct{}, error) {
+			failed <- in
+			return struct{}{}, nil
+		}))
+		p.Extension(pluginstypes.ExtensionConfig{
+			ID:               "monitoring.shutdown",
+			ExtensionPointID: pluginstypes.ExtensionPointShutdown,
+		}, plugins.Implementation(func(ctx context.Context, in pluginstypes.ShutdownEvent) (struct{}, error) {
+			shutdown <- struct{}{}
+			return struct{}{}, nil
+		}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-registered:
+		if event.PluginID != "plugin.monitoring" || len(event.ExtensionIDs) != 2 {
+			t.Fatalf("unexpected registered event %+v", event)
+		}
+	default:
+		t.Fatal("system.pluginRegistered should be executed before LoadInProcess returns")
+	}
+
+	if err := m.LoadPlugins(ctx, "./not-existing-plugin"); err == nil {
+		t.Fatal("error should be returned for not existing plugin")
+	}
+	select {
+	case event := <-failed:
+		if event.Command != "./not-existing-plugin" || event.Error == "" {
+			t.Fatalf("unexpected failed event %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("system.pluginFailed was not executed")
+	}
+
+	// the plugin process exits successfully without the registration
+	if err := m.LoadPlugins(ctx, "true"); err == nil {
+		t.Fatal("error should be returned for the plugin exited before the registration")
+	}
+	select {
+	case event := <-failed:
+		if event.Command != "true" || event.PluginID != "" {
+			t.Fatalf("unexpected failed event %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("system.pluginFailed was not executed for the exited plugin")
+	}
+
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-shutdown:
+	default:
+		t.Fatal("system.shutdown should be executed before Shutdown returns")
+	}
+}
+
+func TestPluginRegisteredOnLateRegistration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m := NewWSManager()
+	registered := make(chan pluginstypes.PluginRegisteredEvent, 2)
+	Extension[pluginstypes.PluginRegisteredEvent, struct{}](m, pluginstypes.ExtensionConfig{
+		ID:               "app.monitoring.registered",
+		ExtensionPointID: pluginstypes.ExtensionPointPluginRegistered,
+	}, func(ctx context.Context, in pluginstypes.PluginRegisteredEvent) (struct{}, error) {
+		registered <- in
+		return struct{}{}, nil
+	})
+	if err := m.LoadInProcess(ctx, "plugin.A", storagePlugin("a.storage")); err != nil {
+		t.Fatal(err)
+	}
+	if event := <-registered; event.PluginID != "plugin.A" {
+		t.Fatalf("unexpected registered event %+v", event)
+	}
+
+	// the plugin connects by itself, e.g. after the reconnection, without LoadPlugins awaiting it
+	connectPlugin(ctx, m, "plugin.B", storagePlugin("b.storage"))
+	select {
+	case event := <-registered:
+		if event.PluginID != "plugin.B" || len(event.ExtensionIDs) != 1 || event.ExtensionIDs[0] != "b.storage" {
+			t.Fatalf("unexpected registered event %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("system.pluginRegistered was not executed for the plugin registered later")
+	}
+}
@@ -3,19 +3,29 @@ package extensionmanager
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/derbylock/go-pluggable-extensions/plugins-host/pkg/random"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/unixsocket"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/websocket"
 	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
-	"log"
 	"log/slog"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrListen is returned by Init when the WSManager can't listen for plugins' connections.
+	ErrListen = errors.New("listen for plugins")
+	// ErrAccept is published with EventServerError when the WSManager stops accepting plugins' connections.
+	ErrAccept = errors.New("accept plugin connection")
 )
 
 type WaiterInfo struct {
@@ -24,30 +34,65 @@ type WaiterInfo struct {
 }
 
 type extensionRuntimeInfo struct {
-	conn               *websocket.Conn
+	pluginID           string
+	loadIndex          int
+	conn               transport.Conn
 	connWaiters        map[string]*WaiterInfo
 	cfg                pluginstypes.ExtensionConfig
 	hostImplementation func(ctx context.Context, in any) (any, error)
+	// condition is the parsed cfg.Condition, it is set when the extension point is resolved.
+	condition pluginstypes.Selector
 }
 
 type failureProcessor func(err error)
 
-// WSManager is a websocket manager that manages websocket connections
-// between the server and the plugins.
+// WSManager is a plugins manager that manages connections between the server and the plugins.
+//
+// It uses WebSocket as a transport by default, see WithTransport to use another one.
 type WSManager struct {
 	debug                                   bool
 	logger                                  *slog.Logger
-	failureProcessor                        failureProcessor
-	lis                                     net.Listener
+	failureSubscription                     *Subscription
+	transport                               transport.Transport
+	bootstrapMode                           BootstrapMode
+	lis                                     transport.Listener
 	pmsPort                                 int
 	mu                                      *sync.Mutex
-	pluginRegistrationChannel               chan string
-	managerErrorsChannel                    chan error
+	eventsMu                                *sync.Mutex
+	subscriptions                           map[*Subscription]struct{}
+	droppedEvents                           *atomic.Uint64
+	registrationWaiterBySecret              map[string]chan string
 	waitersByRequestID                      map[string]*WaiterInfo
 	pluginIDBySecret                        map[string]string
-	channelByPluginID                       map[string]*websocket.Conn
+	pluginProcessBySecret                   map[string]*pluginProcess
+	loadIndexBySecret                       map[string]int
+	nextLoadIndex                           int
+	pluginConfigByPluginID                  map[string]json.RawMessage
+	pluginDisconnectedByPluginID            map[string]chan struct{}
+	knownPluginIDs                          *Set[string]
+	shuttingDown                            bool
+	channelByPluginID                       map[string]transport.Conn
 	extensionRuntimeInfoByExtensionPointIDs map[string][]extensionRuntimeInfo
+	disabledExtensionsByExtensionPointID    map[string][]disabledExtension
+	unorderedExtensionPointIDs              *Set[string]
+	extensionPointConfigByID                map[string]ExtensionPointConfig
+	conflictPolicy                          ConflictPolicy
+	conflictsByPluginID                     map[string][]string
+	conflictsByExtensionPointID             map[string][]Conflict
+	extensionPolicyPath                     string
+	extensionPolicy                         extensionPolicy
+	missingDependencyPolicy                 MissingDependencyPolicy
 	pluginsOrdered                          bool
+	breakerConfig                           *BreakerConfig
+	breakersMu                              *sync.Mutex
+	breakers                                map[breakerKey]*circuitBreaker
+	// now is the clock of circuit breakers, it is replaced in tests.
+	now func() time.Time
+	// loadIndexByPluginID is the load index of the plugin's first registration, it is kept when the plugin restarts.
+	loadIndexByPluginID map[string]int
+	// orderingErrByExtensionPointID are errors of extension points which extensions can't be ordered,
+	// their executions fail until they are ordered again.
+	orderingErrByExtensionPointID map[string]error
 }
 
 // NewWSManager creates a new WSManager instance.
@@ -55,12 +100,33 @@ func NewWSManager() *WSManager {
 	m := &WSManager{
 		mu:                                      &sync.Mutex{},
 		logger:                                  slog.Default(),
-		pluginRegistrationChannel:               make(chan string),
-		managerErrorsChannel:                    make(chan error),
+		eventsMu:                                &sync.Mutex{},
+		subscriptions:                           make(map[*Subscription]struct{}),
+		droppedEvents:                           &atomic.Uint64{},
+		registrationWaiterBySecret:              make(map[string]chan string),
 		waitersByRequestID:                      make(map[string]*WaiterInfo),
 		pluginIDBySecret:                        make(map[string]string),
-		channelByPluginID:                       make(map[string]*websocket.Conn),
+		pluginProcessBySecret:                   make(map[string]*pluginProcess),
+		loadIndexBySecret:                       make(map[string]int),
+		loadIndexByPluginID:                     make(map[string]int),
+		orderingErrByExtensionPointID:           make(map[string]error),
+		nextLoadIndex:                           1,
+		pluginConfigByPluginID:                  make(map[string]json.RawMessage),
+		pluginDisconnectedByPluginID:            make(map[string]chan struct{}),
+		knownPluginIDs:                          NewSet[string](),
+		channelByPluginID:                       make(map[string]transport.Conn),
 		extensionRuntimeInfoByExtensionPointIDs: make(map[string][]extensionRuntimeInfo),
+		disabledExtensionsByExtensionPointID:    make(map[string][]disabledExtension),
+		unorderedExtensionPointIDs:              NewSet[string](),
+		extensionPointConfigByID:                make(map[string]ExtensionPointConfig),
+		conflictPolicy:                          ConflictFail,
+		conflictsByPluginID:                     make(map[string][]string),
+		conflictsByExtensionPointID:             make(map[string][]Conflict),
+		extensionPolicy:                         extensionPolicy{DisabledExtensions: make(map[string]disabledExtensionPolicy)},
+		missingDependencyPolicy:                 MissingDependencyFail,
+		breakersMu:                              &sync.Mutex{},
+		breakers:                                make(map[breakerKey]*circuitBreaker),
+		now:                                     time.Now,
 	}
 
 	return m.WithFailureProcessor(m.DefaultFailureProcessor)
@@ -73,11 +139,32 @@ func (m *WSManager) WithDebug() *WSManager {
 }
 
 // WithFixedPort sets the fixed port for the WSManager.
+//
+// It is used by the default WebSocket transport only.
 func (m *WSManager) WithFixedPort(port int) *WSManager {
 	m.pmsPort = port
 	return m
 }
 
+// WithUnixSocket makes the WSManager accept plugins via the Unix domain socket instead of the TCP port.
+//
+// The socket is created in a private temporary directory accessible by the current user only.
+// Its path is passed to plugins instead of the port.
+// Peer credentials of the connected plugin are checked against the PID of the started plugin process,
+// so the plugin binary should not fork the real plugin process.
+// Connections with secrets of processes not started by LoadPlugins, or with secrets already used,
+// are rejected with ErrPeerRejected.
+func (m *WSManager) WithUnixSocket() *WSManager {
+	m.transport = unixsocket.NewTransport()
+	return m
+}
+
+// WithTransport sets the transport used to accept plugins' connections.
+func (m *WSManager) WithTransport(t transport.Transport) *WSManager {
+	m.transport = t
+	return m
+}
+
 // WithLogger sets the logger for the WSManager.
 func (m *WSManager) WithLogger(logger *slog.Logger) *WSManager {
 	m.logger = logger
@@ -85,8 +172,15 @@ func (m *WSManager) WithLogger(logger *slog.Logger) *WSManager {
 }
 
 // WithFailureProcessor sets the custom failure processor for the WSManager.
+//
+// The processor receives errors of the events published by the WSManager, see Events.
+// It is invoked in a separate goroutine, so it never blocks the WSManager.
+// The previously set processor is unsubscribed.
 func (m *WSManager) WithFailureProcessor(p failureProcessor) *WSManager {
-	m.failureProcessor = p
+	if m.failureSubscription != nil {
+		m.failureSubscription.Close()
+	}
+	m.failureSubscription = m.subscribeFailureProcessor(p)
 	return m
 }
 
@@ -97,30 +191,36 @@ func (m *WSManager) Init() (*WSManager, error) {
 		return m, err
 	}
 	go func() {
-		err := m.startServer()
-		if err != nil {
-			panic(fmt.Errorf("init plugins manager: %w", err))
+		if err := m.startServer(); err != nil {
+			m.publishError(EventServerError, "", fmt.Errorf("init plugins manager: %w", err))
 		}
 	}()
 	return m, nil
 }
 
-func (m *WSManager) handle(w http.ResponseWriter, r *http.Request) {
-	upgrader := websocket.Upgrader{}
-	c, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		m.logger.Error("upgrade:", slog.String("err", err.Error()))
-		return
-	}
+func (m *WSManager) handle(c transport.Conn) {
 	connWaiters := make(map[string]*WaiterInfo)
+	disconnected := make(chan struct{})
+	defer close(disconnected)
 	defer c.Close()
+	registeredPluginID := ""
 	for {
-		mt, inMsg, err := c.ReadMessage()
+		inMsg, err := c.ReadMessage()
 		if err != nil {
 			if m.logger.Enabled(context.Background(), slog.LevelDebug) {
 				m.logger.Debug("read message", slog.String("err", err.Error()))
 			}
 			connWaiters = m.processChannelClosing(connWaiters)
+			if registeredPluginID != "" {
+				m.unregisterPlugin(registeredPluginID, c)
+				if !m.isShuttingDown() {
+					m.publishError(EventPluginFailed, registeredPluginID, fmt.Errorf("plugin disconnected: %w", err))
+					go m.notifySystem(context.Background(), pluginstypes.ExtensionPointPluginFailed, pluginstypes.PluginFailedEvent{
+						PluginID: registeredPluginID,
+						Error:    fmt.Sprintf("plugin disconnected: %s", err.Error()),
+					})
+				}
+			}
 			break
 		}
 
@@ -135,71 +235,135 @@ func (m *WSManager) handle(w http.ResponseWriter, r *http.Request) {
 		ctx := context.Background()
 
 		if exit := func() bool {
-			if mt == websocket.TextMessage {
-				var msg pluginstypes.Message
-				if err := json.Unmarshal(inMsg, &msg); err != nil {
-					//  TODO: handle error
+			var msg pluginstypes.Message
+			if err := json.Unmarshal(inMsg, &msg); err != nil {
+				m.publishError(EventProtocolError, registeredPluginID, fmt.Errorf("unmarshal message: %w", err))
+				// the message ID is unknown, so there is nobody to respond to
+				return false
+			}
+
+			switch msg.Type {
+			case pluginstypes.CommandTypeRegisterPlugin:
+				var registerData pluginstypes.RegisterPluginData
+				if err := json.Unmarshal(msg.Data, &registerData); err != nil {
+					m.publishError(EventProtocolError, "", fmt.Errorf("unmarshal registration data: %w", err))
+					break
+				}
+
+				if err := m.verifyPeer(c, registerData.Secret); err != nil {
+					m.logger.Error(
+						"plugin registration rejected",
+						slog.String("pluginID", registerData.PluginID),
+						slog.String("err", err.Error()),
+					)
+					m.publishError(
+						EventProtocolError,
+						registerData.PluginID,
+						fmt.Errorf("register plugin %s: %w", registerData.PluginID, err),
+					)
 					return true
 				}
 
-				switch msg.Type {
-				case pluginstypes.CommandTypeRegisterPlugin:
-					var registerData pluginstypes.RegisterPluginData
-					if err := json.Unmarshal(msg.Data, &registerData); err != nil {
-						//  TODO: handle error
-						break
+				m.mu.Lock()
+				if m.shuttingDown {
+					m.mu.Unlock()
+					return true
+				}
+				m.channelByPluginID[registerData.PluginID] = c
+				m.pluginDisconnectedByPluginID[registerData.PluginID] = disconnected
+				m.pluginIDBySecret[registerData.Secret] = registerData.PluginID
+				// the secret is used, peers are not verified for some transports
+				delete(m.pluginProcessBySecret, registerData.Secret)
+				registeredPluginID = registerData.PluginID
+				registeredEventType := EventPluginRegistered
+				if m.knownPluginIDs.Contains(registerData.PluginID) {
+					registeredEventType = EventPluginRestarted
+				}
+				m.knownPluginIDs.Add(registerData.PluginID)
+				m.conflictsByPluginID[registerData.PluginID] = registerData.ConflictsWith
+				if _, ok := m.loadIndexBySecret[registerData.Secret]; !ok {
+					// the plugin is not loaded by the host, e.g. reconnected, so it is ordered after the loaded ones
+					m.assignLoadIndex(registerData.Secret)
+				}
+				if _, ok := m.loadIndexByPluginID[registerData.PluginID]; !ok {
+					m.loadIndexByPluginID[registerData.PluginID] = m.loadIndexBySecret[registerData.Secret]
+				}
+				m.detectConflicts(registerData)
+				for _, extensionConfig := range registerData.Extensions {
+					currentExtensionRuntimeInfos, ok := m.extensionRuntimeInfoByExtensionPointIDs[extensionConfig.ExtensionPointID]
+					if !ok {
+						currentExtensionRuntimeInfos = make([]extensionRuntimeInfo, 0)
 					}
+					currentExtensionRuntimeInfos = append(currentExtensionRuntimeInfos, extensionRuntimeInfo{
+						pluginID:    registerData.PluginID,
+						loadIndex:   m.loadIndexByPluginID[registerData.PluginID],
+						conn:        c,
+						connWaiters: connWaiters,
+						cfg:         extensionConfig,
+					})
 
+					m.extensionRuntimeInfoByExtensionPointIDs[extensionConfig.ExtensionPointID] = currentExtensionRuntimeInfos
+					m.unorderedExtensionPointIDs.Add(extensionConfig.ExtensionPointID)
+				}
+				_, awaited := m.registrationWaiterBySecret[registerData.Secret]
+				registeredLate := !awaited && m.pluginsOrdered
+				if registeredLate {
+					// plugins registered outside LoadPlugins, e.g. reconnected ones, are ordered and checked here
+					if err := m.reorderChangedExtensionPoints(); err != nil {
+						m.logger.Error(
+							"order extensions of the registered plugin",
+							slog.String("pluginID", registerData.PluginID),
+							slog.String("err", err.Error()),
+						)
+					}
+					// violations are logged and published as events
+					_ = m.checkCardinalities()
+				}
+				m.mu.Unlock()
+				if err := m.sendRegistrationAck(msg, registerData.PluginID, c); err != nil {
+					m.logger.Error(
+						"send registration acknowledgement",
+						slog.String("pluginID", registerData.PluginID),
+						slog.String("err", err.Error()),
+					)
+				}
+				m.publish(Event{Type: registeredEventType, PluginID: registerData.PluginID})
+				m.registrationCompleted(registerData.Secret)
+				if registeredLate {
+					// the plugin could implement system extensions, and its responses are read by this loop
+					go m.notifySystem(ctx, pluginstypes.ExtensionPointPluginRegistered, m.pluginRegisteredEvent(registerData.PluginID))
+				}
+			case pluginstypes.CommandTypeExecuteExtension:
+				if msg.CorrelationID != "" {
 					m.mu.Lock()
-					m.channelByPluginID[registerData.PluginID] = c
-					for _, extensionConfig := range registerData.Extensions {
-						currentExtensionRuntimeInfos, ok := m.extensionRuntimeInfoByExtensionPointIDs[extensionConfig.ExtensionPointID]
+					func() bool {
+						defer m.mu.Unlock()
+						defer delete(m.waitersByRequestID, msg.CorrelationID)
+						defer delete(connWaiters, msg.CorrelationID)
+						waiter, ok := m.waitersByRequestID[msg.CorrelationID]
 						if !ok {
-							currentExtensionRuntimeInfos = make([]extensionRuntimeInfo, 0)
+							m.publishError(
+								EventProtocolError,
+								registeredPluginID,
+								fmt.Errorf("unknown correlationID %s", msg.CorrelationID),
+							)
+							return true
 						}
-						currentExtensionRuntimeInfos = append(currentExtensionRuntimeInfos, extensionRuntimeInfo{
-							conn:        c,
-							connWaiters: connWaiters,
-							cfg:         extensionConfig,
-						})
 
-						m.extensionRuntimeInfoByExtensionPointIDs[extensionConfig.ExtensionPointID] = currentExtensionRuntimeInfos
-					}
-					ch := c.CloseHandler()
-					c.SetCloseHandler(func(code int, text string) error {
-						connWaiters = m.processChannelClosing(connWaiters)
-						return ch(code, text)
-					})
-					m.mu.Unlock()
-					m.started(registerData.Secret)
-				case pluginstypes.CommandTypeExecuteExtension:
-					if msg.CorrelationID != "" {
-						m.mu.Lock()
-						func() bool {
-							defer m.mu.Unlock()
-							defer delete(m.waitersByRequestID, msg.CorrelationID)
-							defer delete(connWaiters, msg.CorrelationID)
-							waiter, ok := m.waitersByRequestID[msg.CorrelationID]
-							if !ok {
-								m.Failure(fmt.Errorf("unknown correlationID %s", msg.CorrelationID))
-								return true
-							}
-
-							if msg.Error != nil {
-								waiter.ch <- msg.Error
-								return true
-							}
-
-							if err := json.Unmarshal(msg.Data, waiter.out); err != nil {
-								waiter.ch <- err
-								return true
-							}
-							waiter.ch <- waiter.out
-							return false
-						}()
-					} else {
-						go m.processExecuteExtensionRequest(ctx, msg, c)
-					}
+						if msg.Error != nil {
+							waiter.ch <- msg.Error
+							return true
+						}
+
+						if err := json.Unmarshal(msg.Data, waiter.out); err != nil {
+							waiter.ch <- err
+							return true
+						}
+						waiter.ch <- waiter.out
+						return false
+					}()
+				} else {
+					go m.processExecuteExtensionRequest(ctx, msg, c)
 				}
 			}
 			return false
@@ -219,27 +383,35 @@ func (m *WSManager) processChannelClosing(connWaiters map[string]*WaiterInfo) ma
 	m.mu.Unlock()
 
 	for _, wi := range wis {
-		wi.ch <- fmt.Errorf("plugin failed before processing finished")
+		wi.ch <- pluginstypes.NewError(pluginstypes.CodeUnavailable, "plugin failed before processing finished")
 	}
 	return connWaiters
 }
 
-func (m *WSManager) processExecuteExtensionRequest(ctx context.Context, msg pluginstypes.Message, c *websocket.Conn) {
+func (m *WSManager) processExecuteExtensionRequest(ctx context.Context, msg pluginstypes.Message, c transport.Conn) {
 	var executeExtensionData pluginstypes.ExecuteExtensionData
 	if err := json.Unmarshal(msg.Data, &executeExtensionData); err != nil {
-		if errWrite := m.sendErrorResponse(msg, err, c); errWrite != nil {
+		if errWrite := m.sendProtocolErrorResponse(msg, fmt.Errorf("unmarshal execute extension data: %w", err), c); errWrite != nil {
 			m.Failure(errWrite)
 		}
 		return
 	}
+	var opts []pluginstypes.ExecuteOption
+	if requestedOptions := executeExtensionData.Options; requestedOptions != nil {
+		opts = append(opts, func(o *pluginstypes.ExecuteOptions) {
+			*o = *requestedOptions
+		})
+	}
 	results := ExecuteExtensions[json.RawMessage, json.RawMessage](
 		ctx,
 		m,
 		executeExtensionData.ExtensionPointID,
 		executeExtensionData.Data,
+		opts...,
 	)
 	var lastResult *pluginstypes.Message
 	for result := range results {
+		meta := result.Meta
 		if lastResult != nil {
 			if errWrite := m.writeResponse(*lastResult, c); errWrite != nil {
 				lastResult = nil
@@ -253,11 +425,9 @@ func (m *WSManager) processExecuteExtensionRequest(ctx context.Context, msg plug
 			msgResponse := pluginstypes.Message{
 				CorrelationID: msg.MsgID,
 				Type:          pluginstypes.CommandTypeExecuteExtension,
-				Error: &pluginstypes.PluginError{
-					Type:    fmt.Sprintf("%s::%T", "plugins", result.Err),
-					Message: result.Err.Error(),
-				},
-				IsFinal: true,
+				Error:         pluginstypes.NewPluginError("plugins", result.Err),
+				Meta:          &meta,
+				IsFinal:       true,
 			}
 			if errWrite := m.writeResponse(msgResponse, c); errWrite != nil {
 				m.Failure(errWrite)
@@ -277,6 +447,7 @@ func (m *WSManager) processExecuteExtensionRequest(ctx context.Context, msg plug
 			CorrelationID: msg.MsgID,
 			Type:          pluginstypes.CommandTypeExecuteExtension,
 			Data:          dataBytes,
+			Meta:          &meta,
 			IsFinal:       false,
 		}
 		lastResult = &msgResponse
@@ -291,27 +462,29 @@ func (m *WSManager) processExecuteExtensionRequest(ctx context.Context, msg plug
 	}
 }
 
-func (m *WSManager) sendErrorResponse(msg pluginstypes.Message, err error, c *websocket.Conn) error {
+func (m *WSManager) sendErrorResponse(msg pluginstypes.Message, err error, c transport.Conn) error {
 	msgResponse := pluginstypes.Message{
 		CorrelationID: msg.MsgID,
 		Type:          pluginstypes.CommandTypeExecuteExtension,
-		Error: &pluginstypes.PluginError{
-			Type:    fmt.Sprintf("%s::%T", "plugins", err),
-			Message: err.Error(),
-		},
-		IsFinal: true,
+		Error:         pluginstypes.NewPluginError("plugins", err),
+		IsFinal:       true,
 	}
 	errWrite := m.writeResponse(msgResponse, c)
 	return errWrite
 }
 
-func (m *WSManager) writeMessage(c *websocket.Conn, messageType int, data []byte) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	return c.WriteMessage(messageType, data)
+// sendProtocolErrorResponse responds to the malformed request, so the plugin doesn't wait for the result forever.
+func (m *WSManager) sendProtocolErrorResponse(msg pluginstypes.Message, err error, c transport.Conn) error {
+	msgResponse := pluginstypes.Message{
+		CorrelationID: msg.MsgID,
+		Type:          pluginstypes.CommandTypeExecuteExtension,
+		Error:         pluginstypes.NewProtocolError("plugins", err),
+		IsFinal:       true,
+	}
+	return m.writeResponse(msgResponse, c)
 }
 
-func (m *WSManager) writeResponse(msgResponse pluginstypes.Message, c *websocket.Conn) error {
+func (m *WSManager) writeResponse(msgResponse pluginstypes.Message, c transport.Conn) error {
 	msgResponseBytes, err := json.Marshal(msgResponse)
 	if err != nil {
 		return fmt.Errorf("marshal response: %w", err)
@@ -324,7 +497,7 @@ func (m *WSManager) writeResponse(msgResponse pluginstypes.Message, c *websocket
 			slog.String("msg", string(msgResponseBytes)),
 		)
 	}
-	if err := m.writeMessage(c, websocket.TextMessage, msgResponseBytes); err != nil {
+	if err := c.WriteMessage(msgResponseBytes); err != nil {
 		return fmt.Errorf("write message to channel: %w", err)
 	}
 	return nil
@@ -333,115 +506,354 @@ func (m *WSManager) writeResponse(msgResponse pluginstypes.Message, c *websocket
 // ExecuteExtensions executes the extensions for the given extension point ID and input.
 // It returns a channel that will receive the results of the execution.
 // The channel will be closed when all the extensions have been executed or after first error returned.
-func ExecuteExtensions[IN any, OUT any](ctx context.Context, m *WSManager, extensionPointID string, in IN) chan pluginstypes.ExecuteExtensionResult[OUT] {
+//
+// Failed idempotent extensions are executed again according to the retry policy set via pluginstypes.WithRetry.
+// Every attempt is reported in the result metadata.
+//
+// Extensions which conditions don't match the input are not executed, the result without output
+// and with the pluginstypes.ResultMeta.Skipped set is sent for each of them instead.
+func ExecuteExtensions[IN any, OUT any](
+	ctx context.Context,
+	m *WSManager,
+	extensionPointID string,
+	in IN,
+	opts ...pluginstypes.ExecuteOption,
+) chan pluginstypes.ExecuteExtensionResult[OUT] {
+	options := pluginstypes.NewExecuteOptions(opts...)
 	m.mu.Lock()
 	extensionRuntimeInfos := m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID]
+	orderingErr := m.orderingErrByExtensionPointID[extensionPointID]
 	m.mu.Unlock()
 
 	res := make(chan pluginstypes.ExecuteExtensionResult[OUT])
 	go func() {
+		defer close(res)
+		if orderingErr != nil {
+			// extensions are never executed in the registration order
+			res <- pluginstypes.ExecuteExtensionResult[OUT]{
+				Err: fmt.Errorf("extension point %s is not ordered: %w", extensionPointID, orderingErr),
+			}
+			return
+		}
+		extensionRuntimeInfos, err := selectExtensions(extensionRuntimeInfos, options.Selector)
+		if err != nil {
+			res <- pluginstypes.ExecuteExtensionResult[OUT]{Err: err}
+			return
+		}
+		fallbacks := newFallbackTracker(extensionRuntimeInfos)
+		input := conditionInput{in: in}
 		for _, runtimeInfo := range extensionRuntimeInfos {
-			if runtimeInfo.conn == nil {
-				// host extension
-				out, err := runtimeInfo.hostImplementation(ctx, in)
-				res <- pluginstypes.ExecuteExtensionResult[OUT]{
-					Out: out.(OUT),
-					Err: err,
-				}
-				if err != nil {
-					close(res)
-					return
-				}
+			execute, primaryFailure := fallbacks.visit(runtimeInfo.cfg.ID)
+			if !execute {
+				// the primary extension succeeded, is skipped or is replaced by another fallback
 				continue
 			}
 
-			inBytes, err := json.Marshal(in)
+			matches, err := input.matches(runtimeInfo)
 			if err != nil {
-				sendErrorExecuteExtensionResult(res, err)
+				res <- pluginstypes.ExecuteExtensionResult[OUT]{Err: err, Meta: newResultMeta(runtimeInfo)}
 				return
 			}
-
-			msgID := uuid.NewString()
-			msgData := pluginstypes.ExecuteExtensionData{
-				ExtensionPointID: extensionPointID,
-				ExtensionID:      runtimeInfo.cfg.ID,
-				Data:             inBytes,
-			}
-			msgDataBytes, err := json.Marshal(msgData)
-			if err != nil {
-				sendErrorExecuteExtensionResult(res, err)
-				return
+			var (
+				out     OUT
+				meta    pluginstypes.ResultMeta
+				skipped bool
+			)
+			if matches {
+				out, meta, skipped, err = executeWithRetries[IN, OUT](ctx, m, runtimeInfo, extensionPointID, in, options)
 			}
-
-			sendMsg := &pluginstypes.Message{
-				Type:    pluginstypes.CommandTypeExecuteExtension,
-				MsgID:   msgID,
-				Data:    msgDataBytes,
-				IsFinal: true,
+			if (!matches || skipped) && primaryFailure != nil {
+				// the fallback didn't run, so the next fallback replaces the primary extension or its failure is returned
+				if !fallbacks.failed(runtimeInfo.cfg.ID, *primaryFailure) && primaryFailure.err != nil {
+					res <- pluginstypes.ExecuteExtensionResult[OUT]{Err: primaryFailure.err, Meta: primaryFailure.meta}
+					return
+				}
+				continue
 			}
-			sendMsgBytes, err := json.Marshal(sendMsg)
-			if err != nil {
-				sendErrorExecuteExtensionResult(res, err)
-				return
+			if !matches {
+				res <- pluginstypes.ExecuteExtensionResult[OUT]{Meta: skippedMeta(runtimeInfo)}
+				continue
 			}
 
-			ch := make(chan any)
-			m.mu.Lock()
-			var out OUT
-			newWaiterInfo := &WaiterInfo{
-				ch:  ch,
-				out: &out,
+			if primaryFailure != nil {
+				attempts := primaryFailure.meta.Attempts
+				meta.Attempts = append(attempts[:len(attempts):len(attempts)], meta.Attempts...)
 			}
-			m.waitersByRequestID[msgID] = newWaiterInfo
-			runtimeInfo.connWaiters[msgID] = newWaiterInfo
-			m.mu.Unlock()
-			if m.debug {
-				m.logger.Info(
-					"Write message",
-					slog.String("localAddr", runtimeInfo.conn.LocalAddr().String()),
-					slog.String("remoteAddr", runtimeInfo.conn.RemoteAddr().String()),
-					slog.String("msg", string(sendMsgBytes)),
-				)
+			if (err != nil || skipped) && fallbacks.failed(runtimeInfo.cfg.ID, extensionFailure{err: err, meta: meta}) {
+				continue
 			}
-			if err := m.writeMessage(runtimeInfo.conn, websocket.TextMessage, sendMsgBytes); err != nil {
-				sendErrorExecuteExtensionResult(res, err)
-				return
+			if skipped {
+				continue
 			}
-			o := <-ch
-			if err, ok := o.(error); ok {
-				sendErrorExecuteExtensionResult(res, err)
-				return
+			if err == nil {
+				fallbacks.succeeded(runtimeInfo.cfg.ID)
 			}
-			oOut := o.(*OUT)
 			res <- pluginstypes.ExecuteExtensionResult[OUT]{
-				Out: *oOut,
-				Err: nil,
+				Out:  out,
+				Err:  err,
+				Meta: meta,
+			}
+			if err != nil {
+				return
 			}
 		}
-		close(res)
 	}()
 
 	return res
 }
 
+// executeWithRetries executes the extension according to the retry policy.
+// It returns skipped if the extension is skipped because of its open circuit breaker.
+func executeWithRetries[IN any, OUT any](
+	ctx context.Context,
+	m *WSManager,
+	runtimeInfo extensionRuntimeInfo,
+	extensionPointID string,
+	in IN,
+	options pluginstypes.ExecuteOptions,
+) (out OUT, meta pluginstypes.ResultMeta, skipped bool, err error) {
+	meta = newResultMeta(runtimeInfo)
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if options.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, options.Timeout)
+		}
+		out, err = executeExtensionWithBreaker[IN, OUT](attemptCtx, m, runtimeInfo, extensionPointID, in)
+		cancel()
+		if m.breakerConfig != nil && m.breakerConfig.SkipWhenOpen && errors.Is(err, pluginstypes.ErrCircuitOpen) {
+			return out, meta, true, err
+		}
+		meta.Attempts = append(meta.Attempts, newAttempt(runtimeInfo, started, err))
+		if !shouldRetry(ctx, runtimeInfo, options.Retry, attempt, err) {
+			return out, meta, false, err
+		}
+		if !sleep(ctx, options.Retry.Backoff(attempt)) {
+			return out, meta, false, err
+		}
+	}
+}
+
+// newResultMeta describes the result produced by the extension.
+func newResultMeta(runtimeInfo extensionRuntimeInfo) pluginstypes.ResultMeta {
+	return pluginstypes.ResultMeta{
+		ExtensionID: runtimeInfo.cfg.ID,
+		PluginID:    runtimeInfo.pluginID,
+		FallbackFor: runtimeInfo.cfg.FallbackFor,
+	}
+}
+
+// executeExtensionWithBreaker executes the extension once if its circuit breaker allows it.
+func executeExtensionWithBreaker[IN any, OUT any](
+	ctx context.Context,
+	m *WSManager,
+	runtimeInfo extensionRuntimeInfo,
+	extensionPointID string,
+	in IN,
+) (OUT, error) {
+	b := m.breaker(runtimeInfo)
+	if b == nil {
+		return executeExtension[IN, OUT](ctx, m, runtimeInfo, extensionPointID, in)
+	}
+	allowed, state, changed := b.allow(m.now())
+	if changed {
+		m.publishBreakerState(runtimeInfo, state)
+	}
+	if !allowed {
+		var out OUT
+		return out, newCircuitOpenError(runtimeInfo)
+	}
+	out, err := executeExtension[IN, OUT](ctx, m, runtimeInfo, extensionPointID, in)
+	if state, changed := b.record(m.breakerConfig.isFailure(err), m.now()); changed {
+		m.publishBreakerState(runtimeInfo, state)
+	}
+	return out, err
+}
+
+// executeExtension executes the single host or plugin extension once.
+func executeExtension[IN any, OUT any](
+	ctx context.Context,
+	m *WSManager,
+	runtimeInfo extensionRuntimeInfo,
+	extensionPointID string,
+	in IN,
+) (OUT, error) {
+	var out OUT
+	if runtimeInfo.conn == nil {
+		// host extension
+		o, err := invokeHostExtension(ctx, runtimeInfo, in)
+		if pluginstypes.IsPanic(err) {
+			m.publish(Event{
+				Type:             EventExtensionPanic,
+				ExtensionPointID: extensionPointID,
+				Err:              fmt.Errorf("host extension %s: %w", runtimeInfo.cfg.ID, err),
+			})
+		}
+		// o is nil if the extension panicked
+		out, _ = o.(OUT)
+		return out, err
+	}
+
+	inBytes, err := json.Marshal(in)
+	if err != nil {
+		return out, err
+	}
+
+	msgID := uuid.NewString()
+	msgData := pluginstypes.ExecuteExtensionData{
+		ExtensionPointID: extensionPointID,
+		ExtensionID:      runtimeInfo.cfg.ID,
+		Data:             inBytes,
+	}
+	msgDataBytes, err := json.Marshal(msgData)
+	if err != nil {
+		return out, err
+	}
+
+	sendMsg := &pluginstypes.Message{
+		Type:    pluginstypes.CommandTypeExecuteExtension,
+		MsgID:   msgID,
+		Data:    msgDataBytes,
+		IsFinal: true,
+	}
+	sendMsgBytes, err := json.Marshal(sendMsg)
+	if err != nil {
+		return out, err
+	}
+
+	// the channel is buffered, so the response received after the context is done doesn't block the connection
+	ch := make(chan any, 1)
+	m.mu.Lock()
+	newWaiterInfo := &WaiterInfo{
+		ch:  ch,
+		out: &out,
+	}
+	m.waitersByRequestID[msgID] = newWaiterInfo
+	runtimeInfo.connWaiters[msgID] = newWaiterInfo
+	m.mu.Unlock()
+	if m.debug {
+		m.logger.Info(
+			"Write message",
+			slog.String("localAddr", runtimeInfo.conn.LocalAddr().String()),
+			slog.String("remoteAddr", runtimeInfo.conn.RemoteAddr().String()),
+			slog.String("msg", string(sendMsgBytes)),
+		)
+	}
+	if err := runtimeInfo.conn.WriteMessage(sendMsgBytes); err != nil {
+		m.mu.Lock()
+		delete(m.waitersByRequestID, msgID)
+		delete(runtimeInfo.connWaiters, msgID)
+		m.mu.Unlock()
+		return out, pluginstypes.NewError(pluginstypes.CodeUnavailable, fmt.Sprintf("write message: %s", err))
+	}
+	var o any
+	select {
+	case o = <-ch:
+	case <-ctx.Done():
+		m.mu.Lock()
+		delete(m.waitersByRequestID, msgID)
+		delete(runtimeInfo.connWaiters, msgID)
+		m.mu.Unlock()
+		return out, pluginstypes.NewError(
+			pluginstypes.CodeOf(ctx.Err()),
+			fmt.Sprintf("extension %s is not executed: %s", runtimeInfo.cfg.ID, ctx.Err()),
+		)
+	}
+	if err, ok := o.(error); ok {
+		if pluginstypes.IsPanic(err) {
+			m.publish(Event{
+				Type:             EventExtensionPanic,
+				PluginID:         runtimeInfo.pluginID,
+				ExtensionPointID: extensionPointID,
+				Err:              fmt.Errorf("plugin extension %s: %w", runtimeInfo.cfg.ID, err),
+			})
+		}
+		return out, err
+	}
+	return *o.(*OUT), nil
+}
+
+// shouldRetry reports whether the failed attempt of the extension execution should be retried.
+func shouldRetry(
+	ctx context.Context,
+	runtimeInfo extensionRuntimeInfo,
+	policy *pluginstypes.RetryPolicy,
+	attempt int,
+	err error,
+) bool {
+	return err != nil &&
+		policy != nil &&
+		runtimeInfo.cfg.Idempotent &&
+		attempt < policy.MaxAttempts &&
+		ctx.Err() == nil &&
+		policy.Retryable(err)
+}
+
+// sleep waits for the duration and returns false if the context is done earlier.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func newAttempt(runtimeInfo extensionRuntimeInfo, started time.Time, err error) pluginstypes.Attempt {
+	attempt := pluginstypes.Attempt{
+		ExtensionID: runtimeInfo.cfg.ID,
+		Duration:    time.Since(started),
+	}
+	if err != nil {
+		var pluginErr *pluginstypes.PluginError
+		if errors.As(err, &pluginErr) && pluginErr.Source != "" {
+			attempt.Error = pluginErr
+		} else {
+			attempt.Error = pluginstypes.NewPluginError(extensionSource(runtimeInfo), err)
+		}
+	}
+	return attempt
+}
+
+// extensionSource identifies the extension in errors.
+func extensionSource(runtimeInfo extensionRuntimeInfo) string {
+	if runtimeInfo.pluginID == "" {
+		return "plugins::" + runtimeInfo.cfg.ID
+	}
+	return runtimeInfo.pluginID + "::" + runtimeInfo.cfg.ID
+}
+
 func (m *WSManager) listen() error {
-	var err error
-	address := "127.0.0.1:"
-	if m.pmsPort != 0 {
-		address += strconv.Itoa(m.pmsPort)
+	if m.transport == nil {
+		m.transport = websocket.NewTransport("127.0.0.1:" + strconv.Itoa(m.pmsPort))
 	}
-	m.lis, err = net.Listen("tcp", address)
+	var err error
+	m.lis, err = m.transport.Listen()
 	if err != nil {
-		log.Fatal("listen error:", err)
+		return fmt.Errorf("%w: %w", ErrListen, err)
+	}
+	if addr, ok := m.lis.Addr().(*net.TCPAddr); ok {
+		m.pmsPort = addr.Port
 	}
-	m.pmsPort = m.lis.Addr().(*net.TCPAddr).Port
 	return nil
 }
 
 func (m *WSManager) startServer() error {
-	mux := &http.ServeMux{}
-	mux.HandleFunc("/", m.handle)
-	return http.Serve(m.lis, mux)
+	for {
+		c, err := m.lis.Accept()
+		if err != nil {
+			if m.isShuttingDown() {
+				return nil
+			}
+			return fmt.Errorf("%w: %w", ErrAccept, err)
+		}
+		go m.handle(c)
+	}
 }
 
 type WSRegisterArgs struct {
@@ -449,10 +861,23 @@ type WSRegisterArgs struct {
 	HttpPort int
 }
 
-func (m *WSManager) started(secret string) *int {
-	m.pluginRegistrationChannel <- secret
-	i := 0
-	return &i
+// assignLoadIndex remembers the order in which plugins are loaded, it is used to order extensions deterministically.
+// Host extensions have the zero load index, so they go before plugins' extensions with the same priority.
+// m.mu should be locked.
+func (m *WSManager) assignLoadIndex(secret string) {
+	m.loadIndexBySecret[secret] = m.nextLoadIndex
+	m.nextLoadIndex++
+}
+
+// registrationCompleted notifies LoadPlugins waiting for the plugin with the secret.
+func (m *WSManager) registrationCompleted(secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if waiter, ok := m.registrationWaiterBySecret[secret]; ok {
+		delete(m.registrationWaiterBySecret, secret)
+		// the waiter is buffered for all awaited secrets, so it never blocks
+		waiter <- secret
+	}
 }
 
 // LoadPlugins loads the plugins specified by the given commands.
@@ -465,36 +890,85 @@ func (m *WSManager) started(secret string) *int {
 // The function returns an error if any of the plugin commands
 // fail to start.
 func (m *WSManager) LoadPlugins(ctx context.Context, cmds ...string) error {
+	if len(cmds) > 0 && m.lis == nil {
+		return errors.New("plugins manager is not initialized, Init should be called before loading plugins")
+	}
 	waitingSecrets := make(map[string]struct{})
+	registered := make(chan string, len(cmds))
+	failed := make(chan error, len(cmds))
 
 	for _, cmd := range cmds {
 		pluginCommand := cmd
 
 		secret := random.GenerateRandomString(64)
+		process := newPluginProcess()
 		m.mu.Lock()
 		waitingSecrets[secret] = struct{}{}
+		m.pluginProcessBySecret[secret] = process
+		m.registrationWaiterBySecret[secret] = registered
+		m.assignLoadIndex(secret)
 		m.mu.Unlock()
 
+		fail := func(err error) {
+			m.publishError(EventPluginFailed, "", err)
+			// each plugin goroutine fails at most once, so the buffered channel never blocks
+			failed <- err
+		}
+
 		go func() {
 			defer func() {
 				if r := recover(); r != nil {
 					if err, ok := r.(error); ok {
-						m.managerErrorsChannel <- fmt.Errorf("can't start plugin, panic %s: %w", pluginCommand, err)
+						fail(fmt.Errorf("can't start plugin, panic %s: %w", pluginCommand, err))
 					} else {
-						m.managerErrorsChannel <- fmt.Errorf("can't start plugin, panic %s: %v", pluginCommand, r)
+						fail(fmt.Errorf("can't start plugin, panic %s: %v", pluginCommand, r))
 					}
 				}
 			}()
-			command := exec.Command(pluginCommand, "-pms-port", strconv.Itoa(m.pmsPort), "-pms-secret", secret)
+			// the process is forgotten and verifyPeer is unblocked even if the start fails or panics
+			defer m.forgetPluginProcess(secret)
+			defer process.setStarted(nil)
+			command := exec.Command(pluginCommand)
 			if m.debug {
 				command.Stdout = os.Stdout
 				command.Stderr = os.Stderr
 			}
-			if err := command.Start(); err != nil {
-				m.managerErrorsChannel <- fmt.Errorf("can't start plugin %s: %w", pluginCommand, err)
+			releaseBootstrap, err := m.prepareBootstrap(command, secret)
+			if err != nil {
+				fail(fmt.Errorf("can't start plugin %s: %w", pluginCommand, err))
+				return
 			}
-			if err := command.Wait(); err != nil {
-				m.managerErrorsChannel <- fmt.Errorf("can't start plugin command %s: %w", pluginCommand, err)
+			err = command.Start()
+			releaseBootstrap()
+			process.setStarted(command.Process)
+			if err != nil {
+				go m.notifySystem(context.Background(), pluginstypes.ExtensionPointPluginFailed, pluginstypes.PluginFailedEvent{
+					Command: pluginCommand,
+					Error:   err.Error(),
+				})
+				fail(fmt.Errorf("can't start plugin %s: %w", pluginCommand, err))
+				return
+			}
+			waitErr := command.Wait()
+			m.mu.Lock()
+			delete(m.pluginProcessBySecret, secret)
+			_, registered := m.pluginIDBySecret[secret]
+			shuttingDown := m.shuttingDown
+			m.mu.Unlock()
+			if !registered && !shuttingDown {
+				exitErr := errors.New("plugin process exited before the registration")
+				if waitErr != nil {
+					exitErr = fmt.Errorf("%w: %w", exitErr, waitErr)
+				}
+				go m.notifySystem(context.Background(), pluginstypes.ExtensionPointPluginFailed, pluginstypes.PluginFailedEvent{
+					Command: pluginCommand,
+					Error:   exitErr.Error(),
+				})
+				fail(fmt.Errorf("can't start plugin command %s: %w", pluginCommand, exitErr))
+				return
+			}
+			if waitErr != nil {
+				fail(fmt.Errorf("can't start plugin command %s: %w", pluginCommand, waitErr))
 			}
 		}()
 	}
@@ -503,20 +977,39 @@ func (m *WSManager) LoadPlugins(ctx context.Context, cmds ...string) error {
 		if err := m.updateExtensionsOrder(); err != nil {
 			return fmt.Errorf("can't update extensions order: %w", err)
 		}
-		return nil
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.checkCardinalities()
 	}
 
-	return m.awaitPlugins(ctx, waitingSecrets)
+	return m.awaitPlugins(ctx, waitingSecrets, registered, failed)
 }
 
-func (m *WSManager) awaitPlugins(ctx context.Context, waitingSecrets map[string]struct{}) error {
+// awaitPlugins waits until plugins with all waiting secrets are registered,
+// orders the extensions and notifies the system extension points.
+func (m *WSManager) awaitPlugins(
+	ctx context.Context,
+	waitingSecrets map[string]struct{},
+	registered <-chan string,
+	failed <-chan error,
+) error {
+	defer func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for secret := range waitingSecrets {
+			delete(m.registrationWaiterBySecret, secret)
+		}
+	}()
+
+	var registeredPluginIDs []string
 	for {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("awaiting plugins initialization: %w", ctx.Err())
-		case req := <-m.pluginRegistrationChannel:
+		case req := <-registered:
 			m.mu.Lock()
 			delete(waitingSecrets, req)
+			registeredPluginIDs = append(registeredPluginIDs, m.pluginIDBySecret[req])
 			if len(waitingSecrets) == 0 {
 				m.mu.Unlock()
 				if err := m.updateExtensionsOrder(); err != nil {
@@ -525,10 +1018,15 @@ func (m *WSManager) awaitPlugins(ctx context.Context, waitingSecrets map[string]
 				m.mu.Lock()
 				m.pluginsOrdered = true
 				m.mu.Unlock()
-				return nil
+				for _, pluginID := range registeredPluginIDs {
+					m.notifySystem(ctx, pluginstypes.ExtensionPointPluginRegistered, m.pluginRegisteredEvent(pluginID))
+				}
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				return m.checkCardinalities()
 			}
 			m.mu.Unlock()
-		case err := <-m.managerErrorsChannel:
+		case err := <-failed:
 			return err
 		}
 	}
@@ -537,33 +1035,68 @@ func (m *WSManager) awaitPlugins(ctx context.Context, waitingSecrets map[string]
 func (m *WSManager) updateExtensionsOrder() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	// reorder extensions according to order
-	for s, v := range m.extensionRuntimeInfoByExtensionPointIDs {
-		prioritizedExtensionRuntimeInfos, err := OrderExtensionRuntimeInfo(v)
-		if err != nil {
-			m.mu.Unlock()
-			// lock to unlock after in defer
-			m.mu.Lock()
-			return err
+	return m.reorderChangedExtensionPoints()
+}
+
+// reorderChangedExtensionPoints orders extensions of extension points changed since the last ordering.
+// All changed extension points are ordered, errors of the ones which can't be ordered are joined.
+// m.mu should be locked.
+func (m *WSManager) reorderChangedExtensionPoints() error {
+	var errs []error
+	for _, extensionPointID := range m.unorderedExtensionPointIDs.Values() {
+		if err := m.reorderExtensionPoint(extensionPointID); err != nil {
+			errs = append(errs, err)
 		}
-		m.extensionRuntimeInfoByExtensionPointIDs[s] = prioritizedExtensionRuntimeInfos
 	}
+	return errors.Join(errs...)
+}
+
+// reorderExtensionPoint orders extensions of the extension point.
+// If they can't be ordered, executions of the extension point fail with the error until it is ordered again.
+// m.mu should be locked.
+func (m *WSManager) reorderExtensionPoint(extensionPointID string) error {
+	ordered, err := m.resolveExtensionPoint(extensionPointID, m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID])
+	if err != nil {
+		m.orderingErrByExtensionPointID[extensionPointID] = err
+		return err
+	}
+	m.extensionRuntimeInfoByExtensionPointIDs[extensionPointID] = ordered
+	m.unorderedExtensionPointIDs.Remove(extensionPointID)
+	delete(m.orderingErrByExtensionPointID, extensionPointID)
 	return nil
 }
 
+// Failure reports the protocol error to the subscribers of the WSManager events.
 func (m *WSManager) Failure(err error) {
-	m.failureProcessor(err)
+	m.publishError(EventProtocolError, "", err)
 }
 
+// DefaultFailureProcessor logs the error.
 func (m *WSManager) DefaultFailureProcessor(err error) {
-	m.managerErrorsChannel <- err
+	m.logger.Error("plugins manager failure", slog.String("err", err.Error()))
 }
 
-func sendErrorExecuteExtensionResult[OUT any](res chan pluginstypes.ExecuteExtensionResult[OUT], err error) {
-	var o OUT
-	res <- pluginstypes.ExecuteExtensionResult[OUT]{
-		Out: o,
-		Err: err,
+// selectExtensions returns extensions with labels matching the selector.
+func selectExtensions(infos []extensionRuntimeInfo, selector string) ([]extensionRuntimeInfo, error) {
+	if selector == "" {
+		return infos, nil
 	}
-	close(res)
+	parsed, err := pluginstypes.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]extensionRuntimeInfo, 0, len(infos))
+	for _, info := range infos {
+		if parsed.Matches(info.cfg.Labels) {
+			selected = append(selected, info)
+		}
+	}
+	return selected, nil
+}
+
+// invokeHostExtension executes the host extension implementation converting its panic into the PanicError,
+// so the panicking extension doesn't crash the host.
+func invokeHostExtension(ctx context.Context, runtimeInfo extensionRuntimeInfo, in any) (out any, err error) {
+	defer pluginstypes.RecoverPanic(&err)
+	return runtimeInfo.hostImplementation(ctx, in)
 }
@@ -2,6 +2,7 @@ package extensionmanager
 
 import (
 	"context"
+	"errors"
 	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
 	"testing"
 )
@@ -35,3 +36,20 @@ func TestLoadingOrderingError(t *testing.T) {
 		t.Logf("error should be returned")
 	}
 }
+
+func TestInitListenError(t *testing.T) {
+	first, err := NewWSManager().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := first.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	_, err = NewWSManager().WithFixedPort(first.pmsPort).Init()
+	if !errors.Is(err, ErrListen) {
+		t.Fatalf("expected ErrListen, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	types "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+const (
+	// EnvSecret is the name of the environment variable containing the plugin registration secret.
+	EnvSecret = "PMS_SECRET"
+	// EnvPort is the name of the environment variable containing the host's WebSocket port.
+	EnvPort = "PMS_PORT"
+	// EnvSocket is the name of the environment variable containing the host's Unix domain socket path.
+	EnvSocket = "PMS_SOCKET"
+	// EnvBootstrapFD is the name of the environment variable containing the number of the inherited file descriptor
+	// which the host writes types.BootstrapData to.
+	EnvBootstrapFD = "PMS_BOOTSTRAP_FD"
+)
+
+// maxBootstrapDataSize limits the size of the data read from the bootstrap file descriptor.
+const maxBootstrapDataSize = 1 << 20
+
+// The bootstrap file descriptor is a process-wide resource which could be read only once,
+// so its content is cached for all plugin instances.
+var (
+	fdBootstrapOnce sync.Once
+	fdBootstrap     bootstrap
+	fdBootstrapErr  error
+)
+
+// ErrNoHostAddress is returned by Plugin.Run when the host address is not passed to the plugin.
+var ErrNoHostAddress = errors.New("host address is not specified")
+
+// bootstrap contains values required to connect the plugin to the host.
+type bootstrap struct {
+	secret string
+	port   int
+	socket string
+}
+
+func (b bootstrap) hasAddress() bool {
+	return b.port != 0 || b.socket != ""
+}
+
+// merge fills values which are not specified in b with the values of the lower priority source.
+func (b bootstrap) merge(lower bootstrap) bootstrap {
+	if b.secret == "" {
+		b.secret = lower.secret
+	}
+	if !b.hasAddress() {
+		b.port = lower.port
+		b.socket = lower.socket
+	}
+	return b
+}
+
+// bootstrapFromFD reads bootstrap values from the inherited file descriptor specified by the PMS_BOOTSTRAP_FD
+// environment variable. The file descriptor is read and closed once per process.
+func bootstrapFromFD(lookupEnv func(key string) (string, bool)) (bootstrap, error) {
+	fdValue, ok := lookupEnv(EnvBootstrapFD)
+	if !ok || fdValue == "" {
+		return bootstrap{}, nil
+	}
+	fdBootstrapOnce.Do(func() {
+		fdBootstrap, fdBootstrapErr = readBootstrapFD(fdValue)
+	})
+	return fdBootstrap, fdBootstrapErr
+}
+
+func readBootstrapFD(fdValue string) (bootstrap, error) {
+	fd, err := strconv.Atoi(fdValue)
+	if err != nil {
+		return bootstrap{}, fmt.Errorf("parse %s: %w", EnvBootstrapFD, err)
+	}
+	f := os.NewFile(uintptr(fd), "pms-bootstrap")
+	if f == nil {
+		return bootstrap{}, fmt.Errorf("invalid bootstrap file descriptor %d", fd)
+	}
+	defer f.Close()
+
+	var data types.BootstrapData
+	if err := json.NewDecoder(io.LimitReader(f, maxBootstrapDataSize)).Decode(&data); err != nil {
+		return bootstrap{}, fmt.Errorf("read bootstrap data from file descriptor %d: %w", fd, err)
+	}
+	return bootstrap{
+		secret: data.Secret,
+		port:   data.Port,
+		socket: data.Socket,
+	}, nil
+}
+
+// bootstrapFromEnv reads bootstrap values from the environment variables.
+func bootstrapFromEnv(lookupEnv func(key string) (string, bool)) (bootstrap, error) {
+	var b bootstrap
+	b.secret, _ = lookupEnv(EnvSecret)
+	b.socket, _ = lookupEnv(EnvSocket)
+	if port, ok := lookupEnv(EnvPort); ok && port != "" {
+		var err error
+		b.port, err = strconv.Atoi(port)
+		if err != nil {
+			return bootstrap{}, fmt.Errorf("parse %s: %w", EnvPort, err)
+		}
+	}
+	return b, nil
+}
+
+// unsetBootstrapEnv removes the bootstrap environment variables from the process environment,
+// so processes started by the plugin don't inherit the registration secret.
+func unsetBootstrapEnv() {
+	for _, key := range []string{EnvSecret, EnvPort, EnvSocket, EnvBootstrapFD} {
+		_ = os.Unsetenv(key)
+	}
+}
+
+// bootstrapFromArgs reads the legacy -pms-secret, -pms-port and -pms-socket command line arguments.
+//
+// Unlike the flag package, it skips all other arguments, so plugins could use their own flags.
+func bootstrapFromArgs(args []string) (bootstrap, error) {
+	var b bootstrap
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		value, hasValue := "", false
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+		switch name {
+		case "pms-secret", "pms-port", "pms-socket":
+		default:
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return bootstrap{}, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "pms-secret":
+			b.secret = value
+		case "pms-socket":
+			b.socket = value
+		case "pms-port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return bootstrap{}, fmt.Errorf("parse -pms-port: %w", err)
+			}
+			b.port = port
+		}
+	}
+	return b, nil
+}
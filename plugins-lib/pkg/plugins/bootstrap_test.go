@@ -0,0 +1,83 @@
+package plugins
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestBootstrapFromArgs(t *testing.T) {
+	b, err := bootstrapFromArgs([]string{"-v", "--config", "a.yaml", "-pms-port", "8080", "--pms-secret=s3cr3t", "run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.port != 8080 || b.secret != "s3cr3t" || b.socket != "" {
+		t.Fatalf("unexpected bootstrap %+v", b)
+	}
+
+	if _, err := bootstrapFromArgs([]string{"-pms-port"}); err == nil {
+		t.Fatal("error should be returned for the flag without value")
+	}
+}
+
+func TestBootstrapPrecedence(t *testing.T) {
+	env := map[string]string{
+		EnvSecret: "env-secret",
+		EnvPort:   "1234",
+	}
+	fromEnv, err := bootstrapFromEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromArgs, err := bootstrapFromArgs([]string{"-pms-socket", "/tmp/pms.sock", "-pms-secret", "args-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := bootstrap{socket: "/run/explicit.sock"}.merge(fromEnv).merge(fromArgs)
+	if b.socket != "/run/explicit.sock" || b.port != 0 || b.secret != "env-secret" {
+		t.Fatalf("unexpected bootstrap %+v", b)
+	}
+}
+
+func TestBootstrapUnsetsEnv(t *testing.T) {
+	t.Setenv(EnvSecret, "env-secret")
+	t.Setenv(EnvSocket, "/tmp/pms.sock")
+
+	p := New("plugin.A", WithArgs(nil))
+	if _, err := p.resolveBootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if p.bootstrap.secret != "env-secret" || p.bootstrap.socket != "/tmp/pms.sock" {
+		t.Fatalf("unexpected bootstrap %+v", p.bootstrap)
+	}
+	for _, key := range []string{EnvSecret, EnvSocket} {
+		if v, ok := os.LookupEnv(key); ok {
+			t.Fatalf("%s should be unset, got %q", key, v)
+		}
+	}
+}
+
+func TestBootstrapFromFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(`{"secret":"fd-secret","socket":"/tmp/pms.sock"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := readBootstrapFD(strconv.Itoa(int(r.Fd())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.secret != "fd-secret" || b.socket != "/tmp/pms.sock" || b.port != 0 {
+		t.Fatalf("unexpected bootstrap %+v", b)
+	}
+}
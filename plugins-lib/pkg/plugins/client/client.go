@@ -0,0 +1,490 @@
+// Package client implements the plugin side of the protocol independently of the transport used.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+	"github.com/google/uuid"
+	"sync"
+)
+
+var (
+	// ErrDial is returned by Start when the Client can't connect to the host.
+	ErrDial = errors.New("dial host")
+	// ErrHostDisconnected is returned by Start when the connection to the host is lost unexpectedly.
+	ErrHostDisconnected = errors.New("host disconnected")
+	// ErrProtocol is returned by Start or passed to the OnError hook when a message from the host can't be processed.
+	ErrProtocol = errors.New("protocol error")
+)
+
+type WaiterInfo struct {
+	ch  chan executionResult
+	out func() any
+}
+
+// executionResult is a single result of the extension point execution received from the host.
+type executionResult struct {
+	out  any
+	err  error
+	meta pluginstypes.ResultMeta
+}
+
+// Hooks are callbacks invoked by the Client on protocol events. All of them are optional.
+type Hooks struct {
+	// OnConfig is invoked when the host delivers the plugin configuration in the registration acknowledgement.
+	OnConfig func(ctx context.Context, cfg json.RawMessage)
+	// OnConfigChange is invoked when the host pushes the updated plugin configuration at runtime.
+	OnConfigChange func(ctx context.Context, cfg json.RawMessage)
+	// OnRegistered is invoked in a separate goroutine after the host acknowledges the plugin registration,
+	// so it could execute host's extension points.
+	OnRegistered func(ctx context.Context)
+	// OnShutdown is invoked when the host requests the plugin to shut down or the Start context is canceled.
+	// The connection is closed after it returns, so it could still execute host's extension points.
+	OnShutdown func(ctx context.Context)
+	// OnHostDisconnected is invoked when the connection to the host is lost unexpectedly.
+	OnHostDisconnected func(ctx context.Context, err error)
+	// OnError is invoked on errors which don't stop the Client,
+	// e.g. when a message from the host is malformed or a response can't be sent.
+	OnError func(ctx context.Context, err error)
+}
+
+type Client struct {
+	hooks        Hooks
+	pluginID     string
+	pluginSecret string
+	dialer       transport.Dialer
+	extensions   map[string]map[string]*pluginstypes.ExtensionRuntimeInfo
+	conflicts    []string
+	channel      transport.Conn
+	mu           *sync.Mutex
+	waiters      map[string]*WaiterInfo
+	shuttingDown bool
+	closeOnce    *sync.Once
+}
+
+func NewClient(
+	pluginID string,
+	pluginSecret string,
+	dialer transport.Dialer,
+	extensions map[string]map[string]*pluginstypes.ExtensionRuntimeInfo,
+) *Client {
+	return &Client{
+		pluginID:     pluginID,
+		pluginSecret: pluginSecret,
+		dialer:       dialer,
+		extensions:   extensions,
+		mu:           &sync.Mutex{},
+		waiters:      make(map[string]*WaiterInfo),
+		closeOnce:    &sync.Once{},
+	}
+}
+
+// WithHooks sets callbacks invoked on protocol events.
+func (s *Client) WithHooks(hooks Hooks) *Client {
+	s.hooks = hooks
+	return s
+}
+
+// WithConflicts sets IDs of plugins which can't be used together with the plugin, they are sent to the host
+// during the registration.
+func (s *Client) WithConflicts(pluginIDs []string) *Client {
+	s.conflicts = pluginIDs
+	return s
+}
+
+// Start connects to the host, registers the plugin and serves messages until the connection is closed.
+//
+// When the host requests the shutdown or the ctx is canceled, OnShutdown hook is invoked,
+// the connection is closed and Start returns nil.
+//
+// Start returns ErrDial if the host is not reachable and ErrHostDisconnected if the connection is lost.
+// Errors of processing a single message don't stop the Client, they are passed to the OnError hook instead.
+func (s *Client) Start(ctx context.Context) error {
+	c, err := s.initConnection()
+	if err != nil {
+		return fmt.Errorf("initConnection: %w", err)
+	}
+	defer s.closeConnection()
+
+	// handlers and hooks should be able to finish their work after the Start context is canceled
+	runCtx := context.WithoutCancel(ctx)
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.shutdown(runCtx)
+		case <-stopWatching:
+		}
+	}()
+
+	if err := s.registerPlugin(c); err != nil {
+		return err
+	}
+
+	for {
+		msgBytes, err := c.ReadMessage()
+		if err != nil {
+			if s.isShuttingDown() {
+				return nil
+			}
+			e := fmt.Errorf("%w: read message failed: %w", ErrHostDisconnected, err)
+			if s.hooks.OnHostDisconnected != nil {
+				s.hooks.OnHostDisconnected(runCtx, e)
+			}
+			return e
+		}
+
+		ctx := runCtx
+
+		var msg pluginstypes.Message
+		if err := json.Unmarshal(msgBytes, &msg); err != nil {
+			// the message ID is unknown, so there is nobody to respond to
+			s.failure(ctx, fmt.Errorf("%w: unmarshal message: %w", ErrProtocol, err))
+			continue
+		}
+
+		switch msg.Type {
+		case pluginstypes.CommandTypeRegisterPluginAck:
+			var ackData pluginstypes.RegisterPluginAckData
+			if err := json.Unmarshal(msg.Data, &ackData); err != nil {
+				return fmt.Errorf("%w: unmarshal registration acknowledgement: %w", ErrProtocol, err)
+			}
+			if s.hooks.OnConfig != nil {
+				s.hooks.OnConfig(ctx, ackData.Config)
+			}
+			if s.hooks.OnRegistered != nil {
+				go s.hooks.OnRegistered(ctx)
+			}
+		case pluginstypes.CommandTypeShutdown:
+			go s.shutdown(ctx)
+		case pluginstypes.CommandTypeConfigChanged:
+			var configData pluginstypes.ConfigChangedData
+			if err := json.Unmarshal(msg.Data, &configData); err != nil {
+				s.failure(ctx, fmt.Errorf("%w: unmarshal changed config: %w", ErrProtocol, err))
+				break
+			}
+			if s.hooks.OnConfigChange != nil {
+				s.hooks.OnConfigChange(ctx, configData.Config)
+			}
+		case pluginstypes.CommandTypeExecuteExtension:
+			if msg.CorrelationID != "" {
+				// plugin received invocation result
+				if err := s.processExecutionResultMessage(msg); err != nil {
+					s.failure(ctx, err)
+				}
+			} else {
+				// plugin received invocation request
+				go func() {
+					if err := s.processRequest(msg, c, ctx); err != nil {
+						s.failure(ctx, fmt.Errorf("process request %s: %w", msg.MsgID, err))
+					}
+				}()
+			}
+		}
+	}
+}
+
+// failure passes the error which doesn't stop the Client to the OnError hook.
+func (s *Client) failure(ctx context.Context, err error) {
+	if s.hooks.OnError != nil {
+		s.hooks.OnError(ctx, err)
+	}
+}
+
+func (s *Client) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+// shutdown invokes the OnShutdown hook once and closes the connection.
+func (s *Client) shutdown(ctx context.Context) {
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return
+	}
+	s.shuttingDown = true
+	s.mu.Unlock()
+
+	if s.hooks.OnShutdown != nil {
+		s.hooks.OnShutdown(ctx)
+	}
+	s.closeConnection()
+}
+
+func (s *Client) closeConnection() {
+	s.closeOnce.Do(func() {
+		_ = s.channel.Close()
+	})
+}
+
+func (s *Client) initConnection() (transport.Conn, error) {
+	c, err := s.dialer.Dial(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDial, err)
+	}
+	s.channel = c
+	return c, nil
+}
+
+func (s *Client) registerPlugin(c transport.Conn) error {
+	implementedExtensions := make([]pluginstypes.ExtensionConfig, 0)
+	for _, extensionInfos := range s.extensions {
+		for _, info := range extensionInfos {
+			implementedExtensions = append(implementedExtensions, info.Cfg())
+		}
+	}
+
+	msgRegister := pluginstypes.RegisterPluginMessage{
+		Type:  pluginstypes.CommandTypeRegisterPlugin,
+		MsgID: uuid.NewString(),
+		Data: pluginstypes.RegisterPluginData{
+			PluginID:      s.pluginID,
+			Secret:        s.pluginSecret,
+			Extensions:    implementedExtensions,
+			ConflictsWith: s.conflicts,
+		},
+		IsFinal: true,
+	}
+	msgRegisterBytes, err := json.Marshal(msgRegister)
+	if err != nil {
+		return fmt.Errorf("marshal register message: %w", err)
+	}
+	if err := c.WriteMessage(msgRegisterBytes); err != nil {
+		return fmt.Errorf("register plugin: %w", err)
+	}
+	return nil
+}
+
+func (s *Client) processRequest(msg pluginstypes.Message, c transport.Conn, ctx context.Context) error {
+	// plugin extension invoked
+	var executeExtensionData pluginstypes.ExecuteExtensionData
+	if err := json.Unmarshal(msg.Data, &executeExtensionData); err != nil {
+		return s.sendProtocolErrorResponse(msg, fmt.Errorf("unmarshal execute extension data: %w", err), c)
+	}
+	exts, ok := s.extensions[executeExtensionData.ExtensionPointID]
+	if !ok {
+		return s.sendProtocolErrorResponse(
+			msg,
+			pluginstypes.NewError(
+				pluginstypes.CodeNotFound,
+				fmt.Sprintf("unknown extension point %s", executeExtensionData.ExtensionPointID),
+			),
+			c,
+		)
+	}
+	ext, ok := exts[executeExtensionData.ExtensionID]
+	if !ok {
+		return s.sendProtocolErrorResponse(
+			msg,
+			pluginstypes.NewError(
+				pluginstypes.CodeNotFound,
+				fmt.Sprintf("unknown extension %s", executeExtensionData.ExtensionID),
+			),
+			c,
+		)
+	}
+
+	outBytes, err := invokeExtension(ctx, ext, executeExtensionData.Data)
+	if err != nil {
+		if pluginstypes.IsPanic(err) {
+			s.failure(ctx, fmt.Errorf("extension %s: %w", ext.Cfg().ID, err))
+		}
+		return s.sendExtensionErrorResponse(msg, *ext, err, c)
+	}
+
+	msgResponse := pluginstypes.Message{
+		CorrelationID: msg.MsgID,
+		Type:          pluginstypes.CommandTypeExecuteExtension,
+		Data:          outBytes,
+		IsFinal:       true,
+	}
+	return s.writeResponse(msgResponse, c)
+}
+
+// invokeExtension executes the extension implementation converting its panic into the PanicError,
+// so the panicking extension doesn't crash the plugin.
+func invokeExtension(ctx context.Context, ext *pluginstypes.ExtensionRuntimeInfo, data []byte) (outBytes []byte, err error) {
+	defer pluginstypes.RecoverPanic(&err)
+	in, err := ext.Impl().Unmarshaler(data)
+	if err != nil {
+		return nil, err
+	}
+	out, err := ext.Impl().Process(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return ext.Impl().Marshaller(out)
+}
+
+func (s *Client) processExecutionResultMessage(msg pluginstypes.Message) error {
+	if msg.IsFinal {
+		defer func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.waiters, msg.CorrelationID)
+		}()
+	}
+	s.mu.Lock()
+	waiter, ok := s.waiters[msg.CorrelationID]
+	defer s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: unknown correlationID %s", ErrProtocol, msg.CorrelationID)
+	}
+
+	var result executionResult
+	if msg.Meta != nil {
+		result.meta = *msg.Meta
+	}
+	if msg.Error != nil {
+		// the error of the host's extension is the result of the execution, not a failure of the Client
+		result.err = msg.Error
+		waiter.ch <- result
+		return nil
+	}
+
+	outResult := waiter.out()
+	if err := json.Unmarshal(msg.Data, outResult); err != nil {
+		result.err = fmt.Errorf("unmarshal execution result: %w", err)
+		waiter.ch <- result
+		return nil
+	}
+	result.out = outResult
+	waiter.ch <- result
+	if msg.IsFinal {
+		close(waiter.ch)
+	}
+	return nil
+}
+
+// sendProtocolErrorResponse responds to the malformed request, so the host doesn't wait for the result forever.
+func (s *Client) sendProtocolErrorResponse(msg pluginstypes.Message, err error, c transport.Conn) error {
+	msgResponse := pluginstypes.Message{
+		CorrelationID: msg.MsgID,
+		Type:          pluginstypes.CommandTypeExecuteExtension,
+		Error:         pluginstypes.NewProtocolError(s.pluginID, err),
+		IsFinal:       true,
+	}
+	errWrite := s.writeResponse(msgResponse, c)
+	return errWrite
+}
+
+func (s *Client) sendExtensionErrorResponse(msg pluginstypes.Message, ext pluginstypes.ExtensionRuntimeInfo, err error, c transport.Conn) error {
+	msgResponse := pluginstypes.Message{
+		CorrelationID: msg.MsgID,
+		Type:          pluginstypes.CommandTypeExecuteExtension,
+		Error:         pluginstypes.NewPluginError(fmt.Sprintf("%s::%s", s.pluginID, ext.Cfg().ID), err),
+		IsFinal:       true,
+	}
+	errWrite := s.writeResponse(msgResponse, c)
+	return errWrite
+}
+
+func (s *Client) writeResponse(msgResponse pluginstypes.Message, c transport.Conn) error {
+	msgResponseBytes, err := json.Marshal(msgResponse)
+	if err != nil {
+		return fmt.Errorf("marshal response: %w", err)
+	}
+	if err := c.WriteMessage(msgResponseBytes); err != nil {
+		return fmt.Errorf("write message to channel: %w", err)
+	}
+	return nil
+}
+
+// ExecuteExtensions requests the host to execute the extensions of the extension point.
+// The options are sent to the host with the request.
+func ExecuteExtensions[IN any, OUT any](
+	s *Client,
+	extensionPointID string,
+	in IN,
+	opts ...pluginstypes.ExecuteOption,
+) chan pluginstypes.ExecuteExtensionResult[OUT] {
+	res := make(chan pluginstypes.ExecuteExtensionResult[OUT])
+	inBytes, err := json.Marshal(in)
+	if err != nil {
+		sendErrorExecuteExtensionResult(res, fmt.Errorf("marshal input: %w", err))
+		return res
+	}
+	ch := make(chan executionResult)
+	go func() {
+		msgID := uuid.NewString()
+		msgData := pluginstypes.ExecuteExtensionData{
+			ExtensionPointID: extensionPointID,
+			Data:             inBytes,
+		}
+		if len(opts) > 0 {
+			options := pluginstypes.NewExecuteOptions(opts...)
+			msgData.Options = &options
+		}
+		msgDataBytes, err := json.Marshal(msgData)
+		if err != nil {
+			ch <- executionResult{err: fmt.Errorf("marshal ExecuteExtensionData: %w", err)}
+			return
+		}
+
+		sendMsg := &pluginstypes.Message{
+			Type:    pluginstypes.CommandTypeExecuteExtension,
+			MsgID:   msgID,
+			Data:    msgDataBytes,
+			IsFinal: true,
+		}
+		sendMsgBytes, err := json.Marshal(sendMsg)
+		if err != nil {
+			ch <- executionResult{err: fmt.Errorf("marshal plugins.Message: %w", err)}
+			return
+		}
+
+		s.mu.Lock()
+		s.waiters[msgID] = &WaiterInfo{
+			ch: ch,
+			out: func() any {
+				var out OUT
+				return &out
+			},
+		}
+		s.mu.Unlock()
+
+		if err := s.channel.WriteMessage(sendMsgBytes); err != nil {
+			ch <- executionResult{err: fmt.Errorf("write message: %w", err)}
+			s.mu.Lock()
+			delete(s.waiters, msgID)
+			s.mu.Unlock()
+			return
+		}
+	}()
+
+	go func() {
+		for r := range ch {
+			if r.err != nil {
+				res <- pluginstypes.ExecuteExtensionResult[OUT]{
+					Err:  r.err,
+					Meta: r.meta,
+				}
+				close(res)
+				return
+			}
+			res <- pluginstypes.ExecuteExtensionResult[OUT]{
+				Out:  *r.out.(*OUT),
+				Meta: r.meta,
+			}
+		}
+		close(res)
+	}()
+
+	return res
+}
+
+func sendErrorExecuteExtensionResult[OUT any](res chan pluginstypes.ExecuteExtensionResult[OUT], err error) {
+	var o OUT
+	res <- pluginstypes.ExecuteExtensionResult[OUT]{
+		Out: o,
+		Err: err,
+	}
+	close(res)
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/memory"
+	pluginstypes "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+type failingDialer struct{}
+
+func (failingDialer) Dial(context.Context) (transport.Conn, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestStartDialError(t *testing.T) {
+	err := NewClient("plugin.A", "secret", failingDialer{}, nil).Start(context.Background())
+	if !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+}
+
+func TestBadRequestDoesNotStopClient(t *testing.T) {
+	hostConn, pluginConn := memory.Pipe()
+	errs := make(chan error, 1)
+	c := NewClient(
+		"plugin.A",
+		"secret",
+		memory.NewDialer(pluginConn),
+		map[string]map[string]*pluginstypes.ExtensionRuntimeInfo{},
+	).WithHooks(Hooks{
+		OnError: func(ctx context.Context, err error) {
+			errs <- err
+		},
+	})
+	started := make(chan error, 1)
+	go func() {
+		started <- c.Start(context.Background())
+	}()
+
+	if _, err := hostConn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hostConn.WriteMessage([]byte("not a message")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrProtocol) {
+			t.Fatalf("expected ErrProtocol, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError is not invoked")
+	}
+
+	data, _ := json.Marshal(pluginstypes.ExecuteExtensionData{ExtensionPointID: "unknown"})
+	req, _ := json.Marshal(pluginstypes.Message{
+		Type:    pluginstypes.CommandTypeExecuteExtension,
+		MsgID:   "1",
+		Data:    data,
+		IsFinal: true,
+	})
+	if err := hostConn.WriteMessage(req); err != nil {
+		t.Fatal(err)
+	}
+	respBytes, err := hostConn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp pluginstypes.Message
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CorrelationID != "1" || resp.Error == nil || resp.Error.Type != "plugin.A::"+pluginstypes.ErrorTypeProtocol {
+		t.Fatalf("expected protocol error response, got %+v", resp)
+	}
+
+	_ = hostConn.Close()
+	select {
+	case err := <-started:
+		if !errors.Is(err, ErrHostDisconnected) {
+			t.Fatalf("expected ErrHostDisconnected, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start is not finished")
+	}
+}
@@ -0,0 +1,80 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Config returns the raw JSON configuration delivered by the host during the registration or updated later.
+// It returns nil if the host doesn't provide configuration for the plugin.
+func (p *Plugin) Config() json.RawMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// OnConfigChange registers the callback invoked when the host pushes the updated configuration at runtime.
+// The callback is invoked after the new configuration is stored, so Config returns the new value inside it.
+func (p *Plugin) OnConfigChange(callback func(ctx context.Context, cfg json.RawMessage)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configChangeCallbacks = append(p.configChangeCallbacks, callback)
+}
+
+func (p *Plugin) setConfig(cfg json.RawMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = cfg
+}
+
+func (p *Plugin) changeConfig(ctx context.Context, cfg json.RawMessage) {
+	p.mu.Lock()
+	p.config = cfg
+	callbacks := append([]func(ctx context.Context, cfg json.RawMessage){}, p.configChangeCallbacks...)
+	p.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(ctx, cfg)
+	}
+}
+
+// ConfigOf returns the plugin configuration unmarshalled into T.
+// Zero value is returned if the host doesn't provide configuration for the plugin.
+func ConfigOf[T any](p *Plugin) (T, error) {
+	var cfg T
+	raw := p.Config()
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("unmarshal plugin config: %w", err)
+	}
+	return cfg, nil
+}
+
+// OnConfigChangeOf registers the typed callback invoked when the host pushes the updated configuration at runtime.
+// If the new configuration can't be unmarshalled into T, the callback receives the error.
+func OnConfigChangeOf[T any](p *Plugin, callback func(ctx context.Context, cfg T, err error)) {
+	p.OnConfigChange(func(ctx context.Context, raw json.RawMessage) {
+		var cfg T
+		var err error
+		if len(raw) != 0 {
+			if errUnmarshal := json.Unmarshal(raw, &cfg); errUnmarshal != nil {
+				err = fmt.Errorf("unmarshal plugin config: %w", errUnmarshal)
+			}
+		}
+		callback(ctx, cfg, err)
+	})
+}
+
+// Config returns the configuration of the default plugin unmarshalled into T.
+func Config[T any]() (T, error) {
+	return ConfigOf[T](defaultPlugin)
+}
+
+// OnConfigChange registers the typed callback invoked when the host pushes the updated configuration
+// of the default plugin at runtime.
+func OnConfigChange[T any](callback func(ctx context.Context, cfg T, err error)) {
+	OnConfigChangeOf[T](defaultPlugin, callback)
+}
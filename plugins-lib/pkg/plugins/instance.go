@@ -0,0 +1,241 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/client"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/unixsocket"
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport/websocket"
+	types "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+// ErrPluginNotRunning is returned when extensions are executed before the plugin is started via Run.
+var ErrPluginNotRunning = errors.New("plugin is not running")
+
+// Plugin is a plugin instance. It doesn't use any global state,
+// so several plugins could be run in the same process, e.g. inside the host via the in-memory transport.
+//
+// Bootstrap values (host address and registration secret) are taken from the options first,
+// then from the file descriptor inherited from the host (see EnvBootstrapFD), the PMS_* environment variables and,
+// as a legacy fallback, from the -pms-* command line arguments.
+type Plugin struct {
+	pluginID   string
+	bootstrap  bootstrap
+	dialer     transport.Dialer
+	args       []string
+	lookupEnv  func(key string) (string, bool)
+	mu         *sync.Mutex
+	extensions map[string]map[string]*types.ExtensionRuntimeInfo
+	conflicts  []string
+	client     *client.Client
+
+	config                json.RawMessage
+	configChangeCallbacks []func(ctx context.Context, cfg json.RawMessage)
+	hooks                 lifecycleHooks
+}
+
+// Option configures the Plugin.
+type Option func(p *Plugin)
+
+// WithSecret sets the secret used during the plugin registration.
+func WithSecret(secret string) Option {
+	return func(p *Plugin) {
+		p.bootstrap.secret = secret
+	}
+}
+
+// WithPort sets the port of the host's WebSocket server.
+func WithPort(port int) Option {
+	return func(p *Plugin) {
+		p.bootstrap.port = port
+	}
+}
+
+// WithSocket sets the path of the host's Unix domain socket.
+func WithSocket(path string) Option {
+	return func(p *Plugin) {
+		p.bootstrap.socket = path
+	}
+}
+
+// WithDialer sets the dialer used to connect to the host. It overrides the host address from any source.
+func WithDialer(dialer transport.Dialer) Option {
+	return func(p *Plugin) {
+		p.dialer = dialer
+	}
+}
+
+// WithArgs sets the command line arguments used to look up the legacy -pms-* flags. By default, os.Args are used.
+//
+// Arguments which are not related to the plugin bootstrap are ignored, so plugins could parse them using their own flags.
+func WithArgs(args []string) Option {
+	return func(p *Plugin) {
+		p.args = args
+	}
+}
+
+// WithEnv sets the function used to look up bootstrap environment variables.
+// By default, os.LookupEnv is used and the bootstrap variables are unset once they are read,
+// so processes started by the plugin don't inherit the registration secret.
+func WithEnv(lookupEnv func(key string) (string, bool)) Option {
+	return func(p *Plugin) {
+		p.lookupEnv = lookupEnv
+	}
+}
+
+// New creates a new Plugin with the given plugin ID.
+func New(pluginID string, opts ...Option) *Plugin {
+	p := &Plugin{
+		pluginID:   pluginID,
+		args:       os.Args[1:],
+		mu:         &sync.Mutex{},
+		extensions: make(map[string]map[string]*types.ExtensionRuntimeInfo),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ID returns the plugin ID.
+func (p *Plugin) ID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pluginID
+}
+
+// setID sets the plugin ID, it is used by Start of the default plugin.
+func (p *Plugin) setID(pluginID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pluginID = pluginID
+}
+
+// Extension registers an extension with the given configuration and implementation.
+// Use Implementation to create the implementation from the typed function.
+//
+// Extensions should be registered before Run.
+func (p *Plugin) Extension(cfg types.ExtensionConfig, implementation types.ExtensionImplementation[any, any]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	currentExtensions, ok := p.extensions[cfg.ExtensionPointID]
+	if !ok {
+		currentExtensions = make(map[string]*types.ExtensionRuntimeInfo)
+		p.extensions[cfg.ExtensionPointID] = currentExtensions
+	}
+	currentExtensions[cfg.ID] = types.NewExtensionRuntimeInfo(cfg, implementation)
+}
+
+// ConflictsWith declares plugins which can't be used together with the plugin.
+// Depending on the host's policy, the conflict makes plugins loading fail, or the later loaded plugin is dropped.
+//
+// Conflicts should be declared before Run.
+func (p *Plugin) ConflictsWith(pluginIDs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conflicts = append(p.conflicts, pluginIDs...)
+}
+
+// Secret returns the plugin registration secret. It is resolved from all bootstrap sources by Run.
+func (p *Plugin) Secret() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.bootstrap.secret
+}
+
+// Run connects to the host, registers the plugin and serves extensions' invocations until the connection is closed.
+//
+// When the host requests the shutdown or the ctx is canceled, OnShutdown callbacks are invoked and Run returns nil.
+func (p *Plugin) Run(ctx context.Context) error {
+	p.mu.Lock()
+	dialer, err := p.resolveBootstrap()
+	if err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("resolve plugin bootstrap: %w", err)
+	}
+	c := client.NewClient(p.pluginID, p.bootstrap.secret, dialer, p.extensions).WithConflicts(p.conflicts).WithHooks(client.Hooks{
+		OnConfig: func(_ context.Context, cfg json.RawMessage) {
+			p.setConfig(cfg)
+		},
+		OnConfigChange:     p.changeConfig,
+		OnRegistered:       p.registered,
+		OnShutdown:         p.shutdown,
+		OnHostDisconnected: p.hostDisconnected,
+		OnError:            p.failure,
+	})
+	p.client = c
+	p.mu.Unlock()
+	return c.Start(ctx)
+}
+
+// resolveBootstrap fills bootstrap values missing in options and returns the dialer used to connect to the host.
+func (p *Plugin) resolveBootstrap() (transport.Dialer, error) {
+	if p.dialer == nil || p.bootstrap.secret == "" {
+		lookupEnv := p.lookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+			defer unsetBootstrapEnv()
+		}
+		fromFD, err := bootstrapFromFD(lookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		fromEnv, err := bootstrapFromEnv(lookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		fromArgs, err := bootstrapFromArgs(p.args)
+		if err != nil {
+			return nil, err
+		}
+		p.bootstrap = p.bootstrap.merge(fromFD).merge(fromEnv).merge(fromArgs)
+	}
+
+	switch {
+	case p.dialer != nil:
+		return p.dialer, nil
+	case p.bootstrap.socket != "":
+		return unixsocket.NewDialer(p.bootstrap.socket), nil
+	case p.bootstrap.port != 0:
+		return websocket.NewDialer(fmt.Sprintf("127.0.0.1:%d", p.bootstrap.port)), nil
+	default:
+		return nil, ErrNoHostAddress
+	}
+}
+
+// ExecuteExtensions executes the extensions with the given extension point ID and input.
+// Results are returned as raw JSON, see ExecuteExtensionsOf for the typed variant.
+func (p *Plugin) ExecuteExtensions(
+	ctx context.Context,
+	extensionPointID string,
+	in any,
+	opts ...types.ExecuteOption,
+) chan types.ExecuteExtensionResult[json.RawMessage] {
+	return ExecuteExtensionsOf[any, json.RawMessage](ctx, p, extensionPointID, in, opts...)
+}
+
+// ExecuteExtensionsOf executes the extensions with the given extension point ID and input via the plugin.
+func ExecuteExtensionsOf[IN any, OUT any](
+	_ context.Context,
+	p *Plugin,
+	extensionPointID string,
+	in IN,
+	opts ...types.ExecuteOption,
+) chan types.ExecuteExtensionResult[OUT] {
+	p.mu.Lock()
+	c := p.client
+	p.mu.Unlock()
+	if c == nil {
+		res := make(chan types.ExecuteExtensionResult[OUT], 1)
+		res <- types.ExecuteExtensionResult[OUT]{Err: ErrPluginNotRunning}
+		close(res)
+		return res
+	}
+	return client.ExecuteExtensions[IN, OUT](c, extensionPointID, in, opts...)
+}
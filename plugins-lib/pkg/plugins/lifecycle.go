@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"context"
+)
+
+// lifecycleHooks contains callbacks registered for the plugin lifecycle events.
+type lifecycleHooks struct {
+	onRegistered       []func(ctx context.Context)
+	onShutdown         []func(ctx context.Context)
+	onHostDisconnected []func(ctx context.Context, err error)
+	onError            []func(ctx context.Context, err error)
+}
+
+// OnRegistered registers the callback invoked after the host acknowledges the plugin registration.
+//
+// It is invoked in a separate goroutine, so it could execute host's extension points, warm up caches, etc.
+func (p *Plugin) OnRegistered(callback func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks.onRegistered = append(p.hooks.onRegistered, callback)
+}
+
+// OnShutdown registers the callback invoked when the host requests the plugin to shut down
+// or the context passed to Run is canceled.
+//
+// Callbacks are invoked in the reverse order of registration before the connection to the host is closed,
+// so they could release long-lived resources like DB pools and file watchers and still execute host's extension points.
+func (p *Plugin) OnShutdown(callback func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks.onShutdown = append(p.hooks.onShutdown, callback)
+}
+
+// OnHostDisconnected registers the callback invoked when the connection to the host is lost unexpectedly.
+func (p *Plugin) OnHostDisconnected(callback func(ctx context.Context, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks.onHostDisconnected = append(p.hooks.onHostDisconnected, callback)
+}
+
+// OnError registers the callback invoked on errors which don't stop the plugin,
+// e.g. when a message from the host is malformed or a response can't be sent.
+func (p *Plugin) OnError(callback func(ctx context.Context, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks.onError = append(p.hooks.onError, callback)
+}
+
+func (p *Plugin) registered(ctx context.Context) {
+	p.mu.Lock()
+	callbacks := append([]func(ctx context.Context){}, p.hooks.onRegistered...)
+	p.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(ctx)
+	}
+}
+
+func (p *Plugin) shutdown(ctx context.Context) {
+	p.mu.Lock()
+	callbacks := append([]func(ctx context.Context){}, p.hooks.onShutdown...)
+	p.mu.Unlock()
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		callbacks[i](ctx)
+	}
+}
+
+func (p *Plugin) hostDisconnected(ctx context.Context, err error) {
+	p.mu.Lock()
+	callbacks := append([]func(ctx context.Context, err error){}, p.hooks.onHostDisconnected...)
+	p.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(ctx, err)
+	}
+}
+
+func (p *Plugin) failure(ctx context.Context, err error) {
+	p.mu.Lock()
+	callbacks := append([]func(ctx context.Context, err error){}, p.hooks.onError...)
+	p.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(ctx, err)
+	}
+}
+
+// OnRegistered registers the callback invoked after the host acknowledges the default plugin registration.
+func OnRegistered(callback func(ctx context.Context)) {
+	defaultPlugin.OnRegistered(callback)
+}
+
+// OnShutdown registers the callback invoked when the host requests the default plugin to shut down.
+func OnShutdown(callback func(ctx context.Context)) {
+	defaultPlugin.OnShutdown(callback)
+}
+
+// OnHostDisconnected registers the callback invoked when the default plugin loses the connection to the host.
+func OnHostDisconnected(callback func(ctx context.Context, err error)) {
+	defaultPlugin.OnHostDisconnected(callback)
+}
+
+// OnError registers the callback invoked on errors which don't stop the default plugin.
+func OnError(callback func(ctx context.Context, err error)) {
+	defaultPlugin.OnError(callback)
+}
@@ -0,0 +1,30 @@
+package plugins
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	types "github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/types"
+)
+
+func TestStartConcurrentWithExtension(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// there are no bootstrap values in tests, so Start fails
+		_ = Start(ctx, "plugin.race")
+	}()
+	Extension(types.ExtensionConfig{ID: "race.ext", ExtensionPointID: "race"}, func(ctx context.Context, in string) (string, error) {
+		return in, nil
+	})
+	_ = defaultPlugin.ID()
+	wg.Wait()
+	if defaultPlugin.ID() != "plugin.race" {
+		t.Fatalf("unexpected plugin ID %q", defaultPlugin.ID())
+	}
+}
@@ -0,0 +1,151 @@
+// Package memory implements the in-process transport used to run plugins inside the host process.
+//
+// Messages are passed via in-memory queues without any serialization to the network, but the host and the plugin
+// still exchange the same protocol messages as with other transports.
+package memory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+)
+
+// ErrAlreadyDialed is returned by Dialer when its connection was already returned.
+var ErrAlreadyDialed = errors.New("in-memory connection already dialed")
+
+// Addr is the address of the in-memory connection end.
+type Addr string
+
+// Network returns the network name.
+func (a Addr) Network() string {
+	return "memory"
+}
+
+// String returns the address name.
+func (a Addr) String() string {
+	return string(a)
+}
+
+// queue is an unbounded messages queue, so writers never block like with buffered network connections.
+type queue struct {
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	msgs   [][]byte
+	closed bool
+}
+
+func newQueue() *queue {
+	mu := &sync.Mutex{}
+	return &queue{
+		mu:   mu,
+		cond: sync.NewCond(mu),
+	}
+}
+
+func (q *queue) push(msg []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return io.ErrClosedPipe
+	}
+	q.msgs = append(q.msgs, msg)
+	q.cond.Signal()
+	return nil
+}
+
+func (q *queue) pop() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.msgs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := q.msgs[0]
+	q.msgs[0] = nil
+	q.msgs = q.msgs[1:]
+	return msg, nil
+}
+
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// Conn is one end of the in-memory pipe.
+type Conn struct {
+	in     *queue
+	out    *queue
+	local  Addr
+	remote Addr
+}
+
+// Pipe creates an in-memory pair of connected connections.
+//
+// Closing either end closes both directions.
+func Pipe() (hostConn *Conn, pluginConn *Conn) {
+	hostToPlugin := newQueue()
+	pluginToHost := newQueue()
+	hostConn = &Conn{in: pluginToHost, out: hostToPlugin, local: "host", remote: "plugin"}
+	pluginConn = &Conn{in: hostToPlugin, out: pluginToHost, local: "plugin", remote: "host"}
+	return hostConn, pluginConn
+}
+
+// ReadMessage returns the next message written by the other end.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	return c.in.pop()
+}
+
+// WriteMessage sends a copy of the data to the other end. It never blocks.
+func (c *Conn) WriteMessage(data []byte) error {
+	msg := make([]byte, len(data))
+	copy(msg, data)
+	return c.out.push(msg)
+}
+
+// Close closes both directions of the pipe.
+func (c *Conn) Close() error {
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+// LocalAddr returns the local address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// Dialer is a transport.Dialer which returns the prepared in-memory connection.
+type Dialer struct {
+	mu   *sync.Mutex
+	conn *Conn
+}
+
+// NewDialer creates a new Dialer returning the conn on the first Dial call.
+func NewDialer(conn *Conn) *Dialer {
+	return &Dialer{mu: &sync.Mutex{}, conn: conn}
+}
+
+// Dial returns the prepared connection. Subsequent calls return ErrAlreadyDialed.
+func (d *Dialer) Dial(_ context.Context) (transport.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return nil, ErrAlreadyDialed
+	}
+	c := d.conn
+	d.conn = nil
+	return c, nil
+}
@@ -0,0 +1,62 @@
+// Package transport declares the wire abstraction used for communication between the host and plugins.
+//
+// The host and the plugin SDK only depend on the interfaces declared here, so routing, waiters and
+// ordering logic stay the same regardless of the concrete transport (WebSocket, Unix socket, in-memory, etc.).
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrPeerCredentialsUnsupported is returned by PeerCredentialsConn.PeerPID when the platform
+// doesn't allow to retrieve credentials of the peer process.
+var ErrPeerCredentialsUnsupported = errors.New("peer credentials are not supported")
+
+// Conn is a message-oriented bidirectional connection between the host and a plugin.
+//
+// Every ReadMessage call returns exactly one message written by a single WriteMessage call on the other side.
+// Implementations must allow one concurrent reader together with multiple concurrent writers.
+type Conn interface {
+	// ReadMessage blocks until the next message is received or the connection fails.
+	ReadMessage() ([]byte, error)
+	// WriteMessage sends a single message.
+	WriteMessage(data []byte) error
+	// Close closes the connection.
+	Close() error
+	// LocalAddr returns the local address of the connection.
+	LocalAddr() net.Addr
+	// RemoteAddr returns the remote address of the connection.
+	RemoteAddr() net.Addr
+}
+
+// PeerCredentialsConn is implemented by connections which are able to identify the peer process,
+// e.g. via SO_PEERCRED for Unix domain sockets.
+type PeerCredentialsConn interface {
+	Conn
+	// PeerPID returns the process ID of the connected peer.
+	PeerPID() (int, error)
+}
+
+// Listener accepts plugins' connections on the host side.
+type Listener interface {
+	// Accept blocks until the next plugin connects.
+	Accept() (Conn, error)
+	// Close stops listening. Blocked Accept calls return an error.
+	Close() error
+	// Addr returns the address plugins should connect to.
+	Addr() net.Addr
+}
+
+// Transport creates host-side listeners.
+type Transport interface {
+	// Listen starts listening for plugins' connections.
+	Listen() (Listener, error)
+}
+
+// Dialer opens a connection to the host on the plugin side.
+type Dialer interface {
+	// Dial connects to the host.
+	Dial(ctx context.Context) (Conn, error)
+}
@@ -0,0 +1,83 @@
+package unixsocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// MaxMessageSize is the maximum size of a single message accepted by Conn.
+const MaxMessageSize = 64 << 20
+
+// Conn is a transport.Conn implementation over a Unix domain socket.
+//
+// Each message is framed with a 4-byte big-endian length prefix.
+type Conn struct {
+	conn *net.UnixConn
+	r    *bufio.Reader
+	mu   *sync.Mutex
+}
+
+// NewConn wraps the Unix domain socket connection.
+func NewConn(conn *net.UnixConn) *Conn {
+	return &Conn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		mu:   &sync.Mutex{},
+	}
+}
+
+// ReadMessage reads the next length-prefixed message.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("message size %d exceeds the limit of %d bytes", size, MaxMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteMessage writes the length-prefixed message. It is safe for concurrent use.
+func (c *Conn) WriteMessage(data []byte) error {
+	if len(data) > MaxMessageSize {
+		return fmt.Errorf("message size %d exceeds the limit of %d bytes", len(data), MaxMessageSize)
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// PeerPID returns the process ID of the connected peer.
+func (c *Conn) PeerPID() (int, error) {
+	return peerPID(c.conn)
+}
@@ -0,0 +1,28 @@
+package unixsocket
+
+import (
+	"context"
+	"net"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+)
+
+// Dialer is a transport.Dialer which connects to the host's Unix domain socket.
+type Dialer struct {
+	path string
+}
+
+// NewDialer creates a new Dialer for the host listening on the socket path.
+func NewDialer(path string) *Dialer {
+	return &Dialer{path: path}
+}
+
+// Dial connects to the host.
+func (d *Dialer) Dial(ctx context.Context) (transport.Conn, error) {
+	var netDialer net.Dialer
+	c, err := netDialer.DialContext(ctx, "unix", d.path)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c.(*net.UnixConn)), nil
+}
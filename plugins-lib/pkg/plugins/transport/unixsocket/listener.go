@@ -0,0 +1,77 @@
+// Package unixsocket implements the transport over Unix domain sockets.
+//
+// The host listens on a socket created in a private temporary directory accessible by the current user only,
+// so other local users can't connect to it.
+package unixsocket
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+)
+
+const socketFileName = "pms.sock"
+
+// Transport is a transport.Transport which accepts plugins via the Unix domain socket.
+type Transport struct{}
+
+// NewTransport creates a new Unix domain socket transport.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// Listen creates a private (0700) temporary directory and starts listening on the socket inside it.
+func (t *Transport) Listen() (transport.Listener, error) {
+	dir, err := os.MkdirTemp("", "pms-")
+	if err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("chmod socket directory: %w", err)
+	}
+	path := filepath.Join(dir, socketFileName)
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("listen %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = lis.Close()
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return &Listener{lis: lis, dir: dir}, nil
+}
+
+// Listener is a transport.Listener over the Unix domain socket.
+type Listener struct {
+	lis *net.UnixListener
+	dir string
+}
+
+// Accept waits for the next plugin connection.
+func (l *Listener) Accept() (transport.Conn, error) {
+	c, err := l.lis.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c), nil
+}
+
+// Close stops listening and removes the socket directory.
+func (l *Listener) Close() error {
+	err := l.lis.Close()
+	if errRemove := os.RemoveAll(l.dir); errRemove != nil && err == nil {
+		err = errRemove
+	}
+	return err
+}
+
+// Addr returns the socket address.
+func (l *Listener) Addr() net.Addr {
+	return l.lis.Addr()
+}
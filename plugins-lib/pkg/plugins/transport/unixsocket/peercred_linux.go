@@ -0,0 +1,27 @@
+//go:build linux
+
+package unixsocket
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+func peerPID(conn *net.UnixConn) (int, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("get raw connection: %w", err)
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, fmt.Errorf("control raw connection: %w", err)
+	}
+	if credErr != nil {
+		return 0, fmt.Errorf("getsockopt SO_PEERCRED: %w", credErr)
+	}
+	return int(cred.Pid), nil
+}
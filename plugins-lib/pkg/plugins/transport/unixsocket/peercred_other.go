@@ -0,0 +1,13 @@
+//go:build !linux
+
+package unixsocket
+
+import (
+	"net"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+)
+
+func peerPID(_ *net.UnixConn) (int, error) {
+	return 0, transport.ErrPeerCredentialsUnsupported
+}
@@ -0,0 +1,65 @@
+package unixsocket
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRoundTripAndPeerPID(t *testing.T) {
+	lis, err := NewTransport().Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	dirInfo, err := os.Stat(lis.(*Listener).dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := dirInfo.Mode().Perm(); perm != 0o700 {
+		t.Fatalf("socket directory permissions should be 0700, got %o", perm)
+	}
+
+	accepted := make(chan *Conn, 1)
+	go func() {
+		c, err := lis.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- c.(*Conn)
+	}()
+
+	client, err := NewDialer(lis.Addr().String()).Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server := <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	defer server.Close()
+
+	for _, msg := range []string{"first", "", "third message"} {
+		if err := client.WriteMessage([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := server.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Fatalf("expected %q, got %q", msg, got)
+		}
+	}
+
+	pid, err := server.PeerPID()
+	if err != nil {
+		t.Skipf("peer credentials: %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Fatalf("expected peer pid %d, got %d", os.Getpid(), pid)
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"net"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is a transport.Conn implementation over a WebSocket connection.
+//
+// All messages are sent as WebSocket text messages. Messages of other types are skipped on read.
+type Conn struct {
+	conn *websocket.Conn
+	mu   *sync.Mutex
+}
+
+// NewConn wraps the WebSocket connection.
+func NewConn(conn *websocket.Conn) *Conn {
+	return &Conn{
+		conn: conn,
+		mu:   &sync.Mutex{},
+	}
+}
+
+// ReadMessage reads the next text message.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		mt, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if mt == websocket.TextMessage {
+			return data, nil
+		}
+	}
+}
+
+// WriteMessage writes the text message. It is safe for concurrent use.
+func (c *Conn) WriteMessage(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
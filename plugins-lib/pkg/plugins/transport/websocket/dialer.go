@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/gorilla/websocket"
+)
+
+// Dialer is a transport.Dialer which connects to the host's WebSocket server.
+type Dialer struct {
+	address string
+}
+
+// NewDialer creates a new Dialer for the host listening on the TCP address, e.g. "127.0.0.1:8080".
+func NewDialer(address string) *Dialer {
+	return &Dialer{address: address}
+}
+
+// Dial connects to the host.
+func (d *Dialer) Dial(ctx context.Context) (transport.Conn, error) {
+	u := url.URL{Scheme: "ws", Host: d.address, Path: "/"}
+	c, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c), nil
+}
@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/derbylock/go-pluggable-extensions/plugins-lib/pkg/plugins/transport"
+	"github.com/gorilla/websocket"
+)
+
+// ErrListenerClosed is returned by Accept after the listener is closed.
+var ErrListenerClosed = errors.New("websocket listener closed")
+
+// Transport is a transport.Transport which accepts plugins via WebSocket on the TCP address.
+type Transport struct {
+	address string
+}
+
+// NewTransport creates a new WebSocket transport listening on the given TCP address, e.g. "127.0.0.1:0".
+func NewTransport(address string) *Transport {
+	return &Transport{address: address}
+}
+
+// Listen starts the HTTP server which upgrades incoming requests to WebSocket connections.
+func (t *Transport) Listen() (transport.Listener, error) {
+	lis, err := net.Listen("tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", t.address, err)
+	}
+	return NewListener(lis), nil
+}
+
+// Listener is a transport.Listener which upgrades HTTP requests received by the net.Listener to WebSocket connections.
+type Listener struct {
+	lis       net.Listener
+	conns     chan transport.Conn
+	done      chan struct{}
+	closeOnce *sync.Once
+	serveErr  error
+}
+
+// NewListener creates a new Listener and starts serving HTTP requests on the lis.
+func NewListener(lis net.Listener) *Listener {
+	l := &Listener{
+		lis:       lis,
+		conns:     make(chan transport.Conn),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+
+	mux := &http.ServeMux{}
+	mux.HandleFunc("/", l.handle)
+	go func() {
+		err := http.Serve(lis, mux)
+		l.closeOnce.Do(func() {
+			l.serveErr = err
+			close(l.done)
+		})
+	}()
+	return l
+}
+
+func (l *Listener) handle(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader has already replied with the error
+		return
+	}
+	select {
+	case l.conns <- NewConn(c):
+	case <-l.done:
+		_ = c.Close()
+	}
+}
+
+// Accept waits for the next WebSocket connection.
+func (l *Listener) Accept() (transport.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		if l.serveErr != nil {
+			return nil, fmt.Errorf("%w: %w", ErrListenerClosed, l.serveErr)
+		}
+		return nil, ErrListenerClosed
+	}
+}
+
+// Close stops the HTTP server.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return l.lis.Close()
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return l.lis.Addr()
+}
@@ -0,0 +1,73 @@
+package pluginstypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrInvalidCondition is returned when the condition of the extension can't be parsed.
+// It is registered, so errors.Is matches it in plugins too.
+var ErrInvalidCondition = errors.New("invalid extension condition")
+
+// ConditionRootKey is the key of the input itself in the condition, if the input is not an object or an array.
+const ConditionRootKey = "$"
+
+// ParseCondition parses the condition of the extension, see ExtensionConfig.Condition.
+//
+// The condition has the label selector syntax (see ParseSelector) where keys are dot separated paths
+// of the input JSON fields, e.g. `file.path~*.sql, file.size!=0, dryRun notin (true)`.
+// Elements of arrays are referenced by their indexes, e.g. `files.0.path`.
+func ParseCondition(condition string) (Selector, error) {
+	res, err := parseSelector(condition)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidCondition, condition, err)
+	}
+	return res, nil
+}
+
+// ConditionValues flattens the input JSON into values matched by conditions.
+// Strings are used as is, numbers and booleans as their JSON representation, null values are omitted.
+func ConditionValues(input json.RawMessage) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decode input: %w", err)
+	}
+	values := make(map[string]string)
+	flattenConditionValue("", value, values)
+	return values, nil
+}
+
+func flattenConditionValue(path string, value any, values map[string]string) {
+	key := path
+	if key == "" {
+		key = ConditionRootKey
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			flattenConditionValue(joinConditionPath(path, name), field, values)
+		}
+	case []any:
+		for i, element := range v {
+			flattenConditionValue(joinConditionPath(path, strconv.Itoa(i)), element, values)
+		}
+	case string:
+		values[key] = v
+	case json.Number:
+		values[key] = v.String()
+	case bool:
+		values[key] = strconv.FormatBool(v)
+	}
+}
+
+func joinConditionPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
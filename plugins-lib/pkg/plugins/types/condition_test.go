@@ -0,0 +1,48 @@
+package pluginstypes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	input := json.RawMessage(`{"file": {"path": "db/migrations/001.sql", "size": 42, "generated": false}, "tags": ["db"], "owner": null}`)
+	values, err := ConditionValues(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		condition string
+		matches   bool
+	}{
+		{condition: "", matches: true},
+		{condition: "file.path~*.sql", matches: true},
+		{condition: "file.path~*.go", matches: false},
+		{condition: "file.size=42, file.generated!=true", matches: true},
+		{condition: "tags.0 in (db,sql)", matches: true},
+		{condition: "tags.1", matches: false},
+		{condition: "!owner", matches: true},
+	}
+	for _, test := range tests {
+		c, err := ParseCondition(test.condition)
+		if err != nil {
+			t.Fatalf("%q: %v", test.condition, err)
+		}
+		if c.Matches(values) != test.matches {
+			t.Errorf("%q: expected match %v", test.condition, test.matches)
+		}
+	}
+
+	values, err = ConditionValues(json.RawMessage(`"report.sql"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := ParseCondition("$~*.sql"); !c.Matches(values) {
+		t.Errorf("expected the string input to match, values: %v", values)
+	}
+
+	if _, err := ParseCondition("file.path in *.sql"); !errors.Is(err, ErrInvalidCondition) || CodeOf(err) != CodeInvalidArgument {
+		t.Errorf("expected ErrInvalidCondition, got %v", err)
+	}
+}
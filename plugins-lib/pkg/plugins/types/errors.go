@@ -0,0 +1,216 @@
+package pluginstypes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrorTypeProtocol is the suffix of the PluginError type sent in response to the malformed request.
+const ErrorTypeProtocol = "protocol"
+
+// Code is a stable kind of the error which callers could branch on after the error crossed the process boundary.
+type Code string
+
+const (
+	// CodeUnknown is used when the error kind is not known.
+	CodeUnknown Code = "Unknown"
+	// CodeNotFound means that the requested entity is not found.
+	CodeNotFound Code = "NotFound"
+	// CodeInvalidArgument means that the request or its input is malformed.
+	CodeInvalidArgument Code = "InvalidArgument"
+	// CodeUnavailable means that the extension can't be executed now, e.g. its plugin is disconnected.
+	CodeUnavailable Code = "Unavailable"
+	// CodeDeadlineExceeded means that the execution didn't finish before the deadline.
+	CodeDeadlineExceeded Code = "DeadlineExceeded"
+	// CodeCanceled means that the execution was canceled by the caller.
+	CodeCanceled Code = "Canceled"
+	// CodeInternal means that the extension is broken, e.g. it panicked.
+	CodeInternal Code = "Internal"
+)
+
+// PluginError is an error that occurred during the extension's execution.
+//
+// It is sent over the protocol, so the caller of the extension point could check its Code, Details,
+// and match registered sentinel errors with errors.Is, even if the error happened in another process.
+// Extension implementations could return it directly via NewError to set the code and details.
+type PluginError struct {
+	// Type is the type of error. It is informational only, Code should be used to branch on error kinds.
+	Type string `json:"type,omitempty"`
+	// Message is a message that describes the error.
+	Message string `json:"message,omitempty"`
+	// Code is the kind of the error.
+	Code Code `json:"code,omitempty"`
+	// Details are structured details of the error.
+	Details map[string]any `json:"details,omitempty"`
+	// Source identifies the extension or the host which returned the error. It is empty for local errors.
+	Source string `json:"source,omitempty"`
+	// Sentinel is the name of the registered sentinel error the error matches, see RegisterError.
+	Sentinel string `json:"sentinel,omitempty"`
+	// Cause is the error returned by the nested extension execution, if any.
+	Cause *PluginError `json:"cause,omitempty"`
+	// Stack is the stack trace of the panic, if the error is caused by a panic.
+	Stack string `json:"stack,omitempty"`
+}
+
+// NewError creates the error with the code which could be returned by the extension implementation.
+func NewError(code Code, message string) *PluginError {
+	return &PluginError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WithDetail adds the structured detail to the error. The value should be marshallable to JSON.
+func (e *PluginError) WithDetail(key string, value any) *PluginError {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Error returns a string representation of the error.
+func (e PluginError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause of the error, so errors.Is and errors.As follow the chain across nested executions.
+func (e PluginError) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+	return e.Cause
+}
+
+// Is reports whether the error matches the registered sentinel error.
+func (e PluginError) Is(target error) bool {
+	if e.Sentinel == "" {
+		return false
+	}
+	sentinel := registeredError(e.Sentinel)
+	return sentinel != nil && reflect.TypeOf(target).Comparable() && sentinel == target
+}
+
+// CodeOf returns the code of the error.
+func CodeOf(err error) Code {
+	var pluginErr *PluginError
+	var panicErr *PanicError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &pluginErr) && pluginErr.Code != "":
+		return pluginErr.Code
+	case errors.As(err, &panicErr):
+		return CodeInternal
+	case errors.Is(err, ErrCircuitOpen):
+		return CodeUnavailable
+	case errors.Is(err, ErrInvalidSelector), errors.Is(err, ErrInvalidCondition):
+		return CodeInvalidArgument
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeDeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	default:
+		return CodeUnknown
+	}
+}
+
+// NewPluginError creates the PluginError sent in response to the failed execution of the extension.
+// The source identifies the failed extension and is used as a prefix of the error type.
+func NewPluginError(source string, err error) *PluginError {
+	res := &PluginError{
+		Type:     fmt.Sprintf("%s::%T", source, err),
+		Message:  err.Error(),
+		Code:     CodeOf(err),
+		Source:   source,
+		Sentinel: registeredErrorName(err),
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		res.Type = source + "::" + ErrorTypePanic
+		res.Stack = panicErr.Stack
+	}
+	var pluginErr *PluginError
+	if errors.As(err, &pluginErr) {
+		if pluginErr.Source == "" {
+			// the error is created by the extension implementation
+			res.Details = pluginErr.Details
+			res.Cause = pluginErr.Cause
+		} else {
+			// the error is received from the nested execution
+			res.Cause = pluginErr
+		}
+	}
+	return res
+}
+
+// NewProtocolError creates the PluginError sent in response to the malformed request.
+// Its code is CodeInvalidArgument, unless the error has another code.
+func NewProtocolError(source string, err error) *PluginError {
+	res := NewPluginError(source, err)
+	res.Type = source + "::" + ErrorTypeProtocol
+	if res.Code == CodeUnknown {
+		res.Code = CodeInvalidArgument
+	}
+	return res
+}
+
+// ErrCircuitOpen is returned instead of executing the extension when its circuit breaker is open.
+// It is registered, so errors.Is matches it in plugins too.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+func init() {
+	_ = RegisterError("pluginstypes.circuitOpen", ErrCircuitOpen)
+	_ = RegisterError("pluginstypes.invalidSelector", ErrInvalidSelector)
+	_ = RegisterError("pluginstypes.invalidCondition", ErrInvalidCondition)
+}
+
+// ErrErrorAlreadyRegistered is returned by RegisterError when the name is already registered.
+var ErrErrorAlreadyRegistered = errors.New("error is already registered")
+
+var errorsRegistry = struct {
+	mu     sync.RWMutex
+	names  []string
+	byName map[string]error
+}{
+	byName: make(map[string]error),
+}
+
+// RegisterError registers the sentinel error under the name, so errors.Is matches it
+// after the error crossed the process boundary.
+//
+// The same name and error should be registered both by the plugin returning the error and by the caller,
+// e.g. in the init function of a package shared by them.
+// It returns ErrErrorAlreadyRegistered if the name is already registered.
+func RegisterError(name string, err error) error {
+	errorsRegistry.mu.Lock()
+	defer errorsRegistry.mu.Unlock()
+	if _, ok := errorsRegistry.byName[name]; ok {
+		return fmt.Errorf("%w: %s", ErrErrorAlreadyRegistered, name)
+	}
+	errorsRegistry.names = append(errorsRegistry.names, name)
+	errorsRegistry.byName[name] = err
+	return nil
+}
+
+func registeredError(name string) error {
+	errorsRegistry.mu.RLock()
+	defer errorsRegistry.mu.RUnlock()
+	return errorsRegistry.byName[name]
+}
+
+// registeredErrorName returns the name of the first registered sentinel error matching the error.
+func registeredErrorName(err error) string {
+	errorsRegistry.mu.RLock()
+	names := append([]string{}, errorsRegistry.names...)
+	errorsRegistry.mu.RUnlock()
+	for _, name := range names {
+		if errors.Is(err, registeredError(name)) {
+			return name
+		}
+	}
+	return ""
+}
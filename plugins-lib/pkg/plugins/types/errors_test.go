@@ -0,0 +1,84 @@
+package pluginstypes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+func init() {
+	if err := RegisterError("test.userNotFound", errUserNotFound); err != nil {
+		panic(err)
+	}
+}
+
+// roundTrip emulates sending the error over the protocol.
+func roundTrip(t *testing.T, e *PluginError) error {
+	t.Helper()
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res PluginError
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+	return &res
+}
+
+func TestPluginErrorAcrossBoundary(t *testing.T) {
+	local := NewError(CodeNotFound, "user 42").WithDetail("userID", "42")
+	err := roundTrip(t, NewPluginError("plugin.B::users", local))
+	if CodeOf(err) != CodeNotFound {
+		t.Fatalf("expected %s, got %s", CodeNotFound, CodeOf(err))
+	}
+	var pluginErr *PluginError
+	if !errors.As(err, &pluginErr) || pluginErr.Details["userID"] != "42" || pluginErr.Source != "plugin.B::users" {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	err = roundTrip(t, NewPluginError("plugin.B::users", fmt.Errorf("load: %w", errUserNotFound)))
+	if !errors.Is(err, errUserNotFound) {
+		t.Fatalf("expected %v to match the registered sentinel", err)
+	}
+
+	// the error crosses the host and reaches plugin A
+	err = roundTrip(t, NewPluginError("plugins", fmt.Errorf("via host: %w", err)))
+	if !errors.Is(err, errUserNotFound) {
+		t.Fatalf("expected %v to match the registered sentinel after nested hops", err)
+	}
+	if !errors.As(err, &pluginErr) || pluginErr.Cause == nil || pluginErr.Cause.Source != "plugin.B::users" {
+		t.Fatalf("expected the cause chain, got %+v", err)
+	}
+	if errors.Is(err, errors.New("user not found")) {
+		t.Fatal("unregistered error must not match")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		code Code
+	}{
+		{errors.New("err"), CodeUnknown},
+		{context.DeadlineExceeded, CodeDeadlineExceeded},
+		{fmt.Errorf("wrapped: %w", context.Canceled), CodeCanceled},
+		{&PanicError{Value: "broken"}, CodeInternal},
+		{NewProtocolError("plugins", errors.New("bad request")), CodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		if code := CodeOf(tt.err); code != tt.code {
+			t.Errorf("CodeOf(%v): expected %s, got %s", tt.err, tt.code, code)
+		}
+	}
+}
+
+func TestRegisterErrorTwice(t *testing.T) {
+	if err := RegisterError("test.userNotFound", errUserNotFound); !errors.Is(err, ErrErrorAlreadyRegistered) {
+		t.Fatalf("expected ErrErrorAlreadyRegistered, got %v", err)
+	}
+}
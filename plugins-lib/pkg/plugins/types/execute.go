@@ -0,0 +1,136 @@
+package pluginstypes
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultRetryableCodes are error codes retried when RetryPolicy.RetryableCodes is empty.
+var DefaultRetryableCodes = []Code{CodeUnavailable, CodeDeadlineExceeded}
+
+// RetryPolicy defines how failed executions of idempotent extensions are retried.
+//
+// Only the failed extension is executed again, so the order of the extensions is kept.
+// Extensions which are not declared as idempotent in ExtensionConfig are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int `json:"maxAttempts"`
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff limits the delay between attempts, if positive.
+	MaxBackoff time.Duration `json:"maxBackoff,omitempty"`
+	// BackoffMultiplier multiplies the delay after each attempt. The delay is constant if it is less than 1.
+	BackoffMultiplier float64 `json:"backoffMultiplier,omitempty"`
+	// RetryableCodes are codes of errors which should be retried, DefaultRetryableCodes are used if empty.
+	RetryableCodes []Code `json:"retryableCodes,omitempty"`
+}
+
+// Retryable reports whether the error could be retried according to the policy.
+func (p RetryPolicy) Retryable(err error) bool {
+	var panicErr *PanicError
+	if err == nil || errors.As(err, &panicErr) {
+		return false
+	}
+	codes := p.RetryableCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryableCodes
+	}
+	code := CodeOf(err)
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay after the failed attempt with the given number starting from 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && p.BackoffMultiplier > 1; i++ {
+		backoff = time.Duration(float64(backoff) * p.BackoffMultiplier)
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// ExecuteOptions are options of the extension point execution.
+//
+// When a plugin executes the extension point, the options are sent to the host with the request.
+type ExecuteOptions struct {
+	// Retry is the policy of retrying failed idempotent extensions. Extensions are not retried if it is nil.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Selector limits executed extensions to ones with matching labels, see ParseSelector.
+	// All extensions are executed if it is empty.
+	Selector string `json:"selector,omitempty"`
+	// Timeout limits each attempt of the extension execution, if positive.
+	// The extension which doesn't finish in time fails with CodeDeadlineExceeded, so its fallback could be executed.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// ExecuteOption configures the extension point execution.
+type ExecuteOption func(o *ExecuteOptions)
+
+// WithRetry sets the policy of retrying failed idempotent extensions.
+func WithRetry(policy RetryPolicy) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Retry = &policy
+	}
+}
+
+// WithSelector executes only extensions with labels matching the selector,
+// e.g. `lang in (go,sql), stage!=experimental`, see ParseSelector.
+func WithSelector(selector string) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Selector = selector
+	}
+}
+
+// WithTimeout limits each attempt of the extension execution, see ExecuteOptions.Timeout.
+func WithTimeout(timeout time.Duration) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// NewExecuteOptions applies the options to the empty ExecuteOptions.
+func NewExecuteOptions(opts ...ExecuteOption) ExecuteOptions {
+	var o ExecuteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ResultMeta describes how the result of the extension was produced.
+type ResultMeta struct {
+	// ExtensionID is the ID of the executed extension.
+	ExtensionID string `json:"extensionID,omitempty"`
+	// PluginID is the ID of the plugin implementing the extension, it is empty for host extensions.
+	PluginID string `json:"pluginID,omitempty"`
+	// FallbackFor is the ID of the failed or missing primary extension, if the result is produced by its fallback.
+	FallbackFor string `json:"fallbackFor,omitempty"`
+	// Skipped is true if the extension was not executed because its condition doesn't match the input,
+	// see ExtensionConfig.Condition. The result has no output then.
+	Skipped bool `json:"skipped,omitempty"`
+	// SkipReason describes why the extension was skipped.
+	SkipReason string `json:"skipReason,omitempty"`
+	// Attempts are all attempts of the extension execution in order, the last one produced the result.
+	// For fallback results, they start with attempts of the failed primary extension.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt is a single attempt of the extension execution.
+type Attempt struct {
+	// ExtensionID is the ID of the executed extension.
+	ExtensionID string `json:"extensionID,omitempty"`
+	// Duration is the duration of the attempt.
+	Duration time.Duration `json:"duration"`
+	// Error is the error of the failed attempt.
+	Error *PluginError `json:"error,omitempty"`
+}
@@ -0,0 +1,33 @@
+package pluginstypes
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:       5,
+		InitialBackoff:    10 * time.Millisecond,
+		MaxBackoff:        50 * time.Millisecond,
+		BackoffMultiplier: 2,
+	}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if b := p.Backoff(i + 1); b != e*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, e*time.Millisecond, b)
+		}
+	}
+
+	if !p.Retryable(NewError(CodeUnavailable, "unavailable")) {
+		t.Error("Unavailable should be retryable by default")
+	}
+	if p.Retryable(errors.New("unknown")) || p.Retryable(&PanicError{Value: "broken"}) {
+		t.Error("unknown errors and panics should not be retryable")
+	}
+	p.RetryableCodes = []Code{CodeUnknown}
+	if !p.Retryable(errors.New("unknown")) {
+		t.Error("unknown error should be retryable when its code is listed")
+	}
+}
@@ -12,6 +12,12 @@ const (
 	CommandTypeRegisterPlugin = "registerPlugin"
 	// CommandTypeExecuteExtension is a command to execute an extension or return its result.
 	CommandTypeExecuteExtension = "executeExtension"
+	// CommandTypeRegisterPluginAck is sent by the host to acknowledge the plugin registration.
+	CommandTypeRegisterPluginAck = "registerPluginAck"
+	// CommandTypeConfigChanged is sent by the host when the plugin configuration is changed at runtime.
+	CommandTypeConfigChanged = "configChanged"
+	// CommandTypeShutdown is sent by the host to request the plugin to release its resources and disconnect.
+	CommandTypeShutdown = "shutdown"
 )
 
 // Message is a message that can be sent or received.
@@ -26,25 +32,14 @@ type Message struct {
 	Data json.RawMessage `json:"data,omitempty"`
 	// Error is an error that occurred during the processing of the message.
 	Error *PluginError `json:"error,omitempty"`
+	// Meta describes how the result in the response was produced.
+	Meta *ResultMeta `json:"meta,omitempty"`
 	// IsFinal is a flag that indicates whether the message is the last message in a sequence of messages.
 	// It is set to true for responses when current response is the last response
 	// (when there are multiple responses to a single request).
 	IsFinal bool `json:"isFinal,omitempty"`
 }
 
-// PluginError is an error that occurred during the extension's execution.
-type PluginError struct {
-	// Type is the type of error.
-	Type string `json:"type,omitempty"`
-	// Message is a message that describes the error.
-	Message string `json:"message,omitempty"`
-}
-
-// Error returns a string representation of the error.
-func (e PluginError) Error() string {
-	return e.Message
-}
-
 // RegisterPluginData is the data that is sent with a registerPlugin command.
 type RegisterPluginData struct {
 	// PluginID is the ID of the plugin.
@@ -53,6 +48,30 @@ type RegisterPluginData struct {
 	Secret string `json:"secret"`
 	// Extensions is a list of extensions that the plugin provides.
 	Extensions []ExtensionConfig `json:"extensions"`
+	// ConflictsWith is a list of IDs of plugins which can't be used together with the plugin.
+	ConflictsWith []string `json:"conflictsWith,omitempty"`
+}
+
+// RegisterPluginAckData is the data that is sent with a registerPluginAck command.
+type RegisterPluginAckData struct {
+	// Config is the plugin configuration provided by the host.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// ConfigChangedData is the data that is sent with a configChanged command.
+type ConfigChangedData struct {
+	// Config is the new plugin configuration.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// BootstrapData is the data passed by the host to the plugin via the inherited file descriptor.
+type BootstrapData struct {
+	// Secret is a secret that is used to authenticate the plugin.
+	Secret string `json:"secret"`
+	// Port is the port of the host's WebSocket server.
+	Port int `json:"port,omitempty"`
+	// Socket is the path of the host's Unix domain socket.
+	Socket string `json:"socket,omitempty"`
 }
 
 // ExtensionConfig is the configuration of an extension.
@@ -61,10 +80,39 @@ type ExtensionConfig struct {
 	ID string
 	// ExtensionPointID is the ID of the extension point that the extension implements.
 	ExtensionPointID string
+	// Description is the human-readable description of the extension.
+	Description string
+	// Labels are free-form key-value labels of the extension, e.g. `stage=experimental` or `lang=go`.
+	// They are used to execute a subset of extensions of the extension point, see WithSelector.
+	Labels map[string]string
+	// Condition limits inputs the extension is executed with, e.g. `file.path~*.sql`, see ParseCondition.
+	// The host evaluates it before the execution, so non-matching extensions are skipped without calling the plugin.
+	// The extension is executed with any input if it is empty.
+	Condition string
 	// BeforeExtensionIDs is a list of IDs of extensions that the extension should be executed before.
 	BeforeExtensionIDs []string
 	// AfterExtensionIDs is a list of IDs of extensions that the extension should be executed after.
 	AfterExtensionIDs []string
+	// RequiresExtensionIDs is a list of IDs of extensions that must be registered for the same extension point.
+	// The extension is executed after them. Unlike AfterExtensionIDs, the missing extension makes plugins loading
+	// fail or disables the extension, depending on the host's policy.
+	RequiresExtensionIDs []string
+	// Priority orders extensions without Before/After constraints between them: higher priority executes earlier.
+	// Extensions with the same priority are ordered by the plugins load order and then by ID.
+	Priority int
+	// Replaces is the ID of the extension of the same extension point which this extension replaces, e.g. the default
+	// host extension. The replaced extension is not executed, and the replacing one takes its place in the order.
+	// Several extensions replacing the same extension make the ordering fail.
+	Replaces string
+	// ConflictsWith is a list of IDs of extensions of the same extension point which can't be executed together
+	// with the extension. Depending on the host's policy, the conflict makes the ordering fail,
+	// or the lower-priority extension is dropped.
+	ConflictsWith []string
+	// Idempotent declares that the extension could be safely executed again after a failure, see RetryPolicy.
+	Idempotent bool
+	// FallbackFor is the ID of the primary extension which this extension replaces when the primary one fails
+	// or is not registered. The fallback takes the primary's position in the order.
+	FallbackFor string
 }
 
 // RegisterPluginMessage is a message that is sent to register a plugin.
@@ -89,4 +137,6 @@ type ExecuteExtensionData struct {
 	ExtensionID string `json:"extensionID"`
 	// Data is the data that should be passed to the extension.
 	Data json.RawMessage `json:"data"`
+	// Options are options of the extension point execution requested by a plugin.
+	Options *ExecuteOptions `json:"options,omitempty"`
 }
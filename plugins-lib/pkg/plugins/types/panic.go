@@ -0,0 +1,49 @@
+package pluginstypes
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// ErrorTypePanic is the suffix of the PluginError type sent when the extension implementation panics.
+const ErrorTypePanic = "panic"
+
+// PanicError is returned instead of the extension result when the extension implementation panics.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace captured when the panic was recovered.
+	Stack string
+}
+
+// Error returns a string representation of the error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("extension panicked: %v", e.Value)
+}
+
+// RecoverPanic recovers the panic and stores it into err as the PanicError.
+// It should be deferred directly by the function invoking the extension implementation.
+func RecoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Value: r,
+			Stack: string(debug.Stack()),
+		}
+	}
+}
+
+// IsPanic reports whether the error is caused by a panic in the extension implementation,
+// either in the current process or in the remote one.
+func IsPanic(err error) bool {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return true
+	}
+	var pluginErr *PluginError
+	if errors.As(err, &pluginErr) {
+		return strings.HasSuffix(pluginErr.Type, "::"+ErrorTypePanic)
+	}
+	return false
+}
@@ -8,6 +8,8 @@ import "context"
 type ExecuteExtensionResult[OUT any] struct {
 	Out OUT
 	Err error
+	// Meta describes how the result was produced, e.g. attempts of the execution.
+	Meta ResultMeta
 }
 
 // ExtensionRuntimeInfo is a struct that contains information about an extension.
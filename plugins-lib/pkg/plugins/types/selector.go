@@ -0,0 +1,228 @@
+package pluginstypes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidSelector is returned when the label selector can't be parsed.
+// It is registered, so errors.Is matches it in plugins too.
+var ErrInvalidSelector = errors.New("invalid label selector")
+
+// SelectorOperator is the operator of the label selector requirement.
+type SelectorOperator string
+
+const (
+	// SelectorEquals requires the label to have the value: `key=value` or `key==value`.
+	SelectorEquals SelectorOperator = "="
+	// SelectorNotEquals requires the label to be missing or to have another value: `key!=value`.
+	SelectorNotEquals SelectorOperator = "!="
+	// SelectorIn requires the label to have one of the values: `key in (a,b)`.
+	SelectorIn SelectorOperator = "in"
+	// SelectorNotIn requires the label to be missing or to have none of the values: `key notin (a,b)`.
+	SelectorNotIn SelectorOperator = "notin"
+	// SelectorExists requires the label to be set: `key`.
+	SelectorExists SelectorOperator = "exists"
+	// SelectorNotExists requires the label to be missing: `!key`.
+	SelectorNotExists SelectorOperator = "!"
+	// SelectorGlob requires the label to match the glob pattern: `key~*.sql`.
+	// The `*` matches any sequence of characters including `/`, the `?` matches any single character.
+	SelectorGlob SelectorOperator = "~"
+)
+
+// SelectorRequirement is a single requirement of the label selector.
+type SelectorRequirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+	// pattern is the compiled glob pattern of SelectorGlob, so such requirements should be created via ParseSelector.
+	pattern *regexp.Regexp
+}
+
+// Selector selects extensions by their labels, see ExtensionConfig.Labels.
+// The extension matches the selector if it matches all requirements, the empty selector matches all extensions.
+type Selector []SelectorRequirement
+
+// ParseSelector parses the comma separated list of requirements in the Kubernetes label selector syntax,
+// e.g. `lang in (go,sql), stage!=experimental, !deprecated`, extended with glob patterns, e.g. `file~*.sql`.
+func ParseSelector(selector string) (Selector, error) {
+	res, err := parseSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSelector, selector, err)
+	}
+	return res, nil
+}
+
+func parseSelector(selector string) (Selector, error) {
+	var res Selector
+	for _, part := range splitSelector(selector) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if strings.TrimSpace(selector) == "" {
+				continue
+			}
+			return nil, errors.New("empty requirement")
+		}
+		requirement, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, requirement)
+	}
+	return res, nil
+}
+
+// splitSelector splits the selector by commas outside parentheses.
+func splitSelector(selector string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, r := range selector {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseRequirement(s string) (SelectorRequirement, error) {
+	if key, ok := strings.CutPrefix(s, "!"); ok {
+		return newRequirement(key, SelectorNotExists, nil)
+	}
+	// the operator follows the key, values could contain operator characters, e.g. `env in (a=b)`
+	if keyEnd := strings.IndexAny(s, " \t!=~(),"); keyEnd >= 0 {
+		rest := strings.TrimLeft(s[keyEnd:], " \t")
+		for _, op := range []struct {
+			token    string
+			operator SelectorOperator
+		}{
+			{token: "!=", operator: SelectorNotEquals},
+			{token: "==", operator: SelectorEquals},
+			{token: "=", operator: SelectorEquals},
+			{token: "~", operator: SelectorGlob},
+		} {
+			if value, ok := strings.CutPrefix(rest, op.token); ok {
+				return newRequirement(s[:keyEnd], op.operator, []string{strings.TrimSpace(value)})
+			}
+		}
+		if strings.HasPrefix(rest, "!") {
+			return SelectorRequirement{}, fmt.Errorf("unknown requirement %q", s)
+		}
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 1 {
+		return newRequirement(fields[0], SelectorExists, nil)
+	}
+	if len(fields) < 2 || (fields[1] != string(SelectorIn) && fields[1] != string(SelectorNotIn)) {
+		return SelectorRequirement{}, fmt.Errorf("unknown requirement %q", s)
+	}
+	set := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), fields[0]))
+	set = strings.TrimSpace(strings.TrimPrefix(set, fields[1]))
+	if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+		return SelectorRequirement{}, fmt.Errorf("values of %q should be enclosed in parentheses", s)
+	}
+	var values []string
+	for _, value := range strings.Split(set[1:len(set)-1], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return SelectorRequirement{}, fmt.Errorf("no values in %q", s)
+	}
+	return newRequirement(fields[0], SelectorOperator(fields[1]), values)
+}
+
+func newRequirement(key string, operator SelectorOperator, values []string) (SelectorRequirement, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.ContainsAny(key, " !=~(),") {
+		return SelectorRequirement{}, fmt.Errorf("invalid label key %q", key)
+	}
+	requirement := SelectorRequirement{Key: key, Operator: operator, Values: values}
+	if operator == SelectorGlob {
+		requirement.pattern = compileGlob(values[0])
+	}
+	return requirement, nil
+}
+
+// compileGlob converts the glob pattern into the regular expression matching the whole value.
+func compileGlob(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Matches reports whether the labels match all requirements of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the labels match the requirement.
+func (r SelectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case SelectorEquals, SelectorIn:
+		return ok && slices.Contains(r.Values, value)
+	case SelectorNotEquals, SelectorNotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case SelectorExists:
+		return ok
+	case SelectorNotExists:
+		return !ok
+	case SelectorGlob:
+		return ok && r.pattern != nil && r.pattern.MatchString(value)
+	default:
+		return false
+	}
+}
+
+// String returns the selector in the canonical form accepted by ParseSelector.
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		switch r.Operator {
+		case SelectorExists:
+			parts = append(parts, r.Key)
+		case SelectorNotExists:
+			parts = append(parts, "!"+r.Key)
+		case SelectorIn, SelectorNotIn:
+			values := append([]string(nil), r.Values...)
+			sort.Strings(values)
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(values, ",")))
+		default:
+			parts = append(parts, r.Key+string(r.Operator)+strings.Join(r.Values, ""))
+		}
+	}
+	return strings.Join(parts, ",")
+}
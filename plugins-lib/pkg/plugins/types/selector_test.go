@@ -0,0 +1,49 @@
+package pluginstypes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"lang": "go", "stage": "stable", "linter": "vet"}
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{selector: "", matches: true},
+		{selector: "lang=go", matches: true},
+		{selector: "lang==sql", matches: false},
+		{selector: "lang in (go,sql), stage!=experimental", matches: true},
+		{selector: "lang notin (go, sql)", matches: false},
+		{selector: "linter in (vet)", matches: true},
+		{selector: "stage", matches: true},
+		{selector: "!deprecated", matches: true},
+		{selector: "!stage", matches: false},
+		{selector: "deprecated!=true", matches: true},
+		{selector: "linter~v*", matches: true},
+		{selector: "lang~?o, stage~*able", matches: true},
+		{selector: "lang~g", matches: false},
+		{selector: "lang = go", matches: true},
+		{selector: "stage in (a=b, stable)", matches: true},
+		{selector: "linter notin (x~y, !z)", matches: true},
+	}
+	for _, test := range tests {
+		s, err := ParseSelector(test.selector)
+		if err != nil {
+			t.Fatalf("%q: %v", test.selector, err)
+		}
+		if s.Matches(labels) != test.matches {
+			t.Errorf("%q: expected match %v", test.selector, test.matches)
+		}
+		if reparsed, err := ParseSelector(s.String()); err != nil || reparsed.String() != s.String() {
+			t.Errorf("%q: canonical form %q is not parsed back: %v", test.selector, s.String(), err)
+		}
+	}
+
+	for _, selector := range []string{"lang in go", "lang in ()", "=go", "lang=go,,stage", "a b c", "lang!go"} {
+		if _, err := ParseSelector(selector); !errors.Is(err, ErrInvalidSelector) || CodeOf(err) != CodeInvalidArgument {
+			t.Errorf("%q: expected ErrInvalidSelector, got %v", selector, err)
+		}
+	}
+}
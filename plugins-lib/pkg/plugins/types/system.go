@@ -0,0 +1,36 @@
+package pluginstypes
+
+// Reserved extension points executed by the host itself on lifecycle events.
+// Both the host and plugins could implement them to react on events, e.g. to alert on plugins' crashes.
+const (
+	// ExtensionPointPluginRegistered is executed with PluginRegisteredEvent after a plugin is registered
+	// and extensions are ordered.
+	ExtensionPointPluginRegistered = "system.pluginRegistered"
+	// ExtensionPointPluginFailed is executed with PluginFailedEvent when a plugin can't be started
+	// or disconnects unexpectedly.
+	ExtensionPointPluginFailed = "system.pluginFailed"
+	// ExtensionPointShutdown is executed with ShutdownEvent when the host starts shutting down,
+	// before plugins are requested to disconnect.
+	ExtensionPointShutdown = "system.shutdown"
+)
+
+// PluginRegisteredEvent is the input of the ExtensionPointPluginRegistered extensions.
+type PluginRegisteredEvent struct {
+	// PluginID is the ID of the registered plugin.
+	PluginID string `json:"pluginID"`
+	// ExtensionIDs is a list of IDs of extensions provided by the plugin.
+	ExtensionIDs []string `json:"extensionIDs,omitempty"`
+}
+
+// PluginFailedEvent is the input of the ExtensionPointPluginFailed extensions.
+type PluginFailedEvent struct {
+	// PluginID is the ID of the failed plugin. It is empty when the plugin failed before the registration.
+	PluginID string `json:"pluginID,omitempty"`
+	// Command is the command used to start the plugin. It is empty for plugins which were not started by the host.
+	Command string `json:"command,omitempty"`
+	// Error is the failure description.
+	Error string `json:"error"`
+}
+
+// ShutdownEvent is the input of the ExtensionPointShutdown extensions.
+type ShutdownEvent struct{}